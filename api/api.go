@@ -0,0 +1,10 @@
+// Package api embeds the OpenAPI specification of the service.
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI specification of the service in YAML, from which
+// pkg/api is generated.
+//
+//go:embed interface.yaml
+var Spec []byte
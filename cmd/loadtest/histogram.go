@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits sets the precision of the histogram: values are recorded in
+// buckets at most 1/2^subBucketBits wide relative to their value, that is
+// within 1%.
+const subBucketBits = 7
+
+// histogram records durations in microseconds into log-linear buckets, like
+// an HDR histogram: values below 2^(subBucketBits+1) are exact, and every
+// further power of two is split into 2^subBucketBits buckets.
+type histogram struct {
+	counts []int64
+	total  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+func bucketIndex(v int64) int {
+	shift := max(0, bits.Len64(uint64(v))-subBucketBits-1)
+
+	return shift<<subBucketBits + int(v>>shift)
+}
+
+// bucketUpperBound returns the highest value recorded in the bucket.
+func bucketUpperBound(i int) int64 {
+	if i < 2<<subBucketBits {
+		return int64(i)
+	}
+
+	shift := i>>subBucketBits - 1
+	m := int64(i - shift<<subBucketBits)
+
+	return (m+1)<<shift - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := max(0, d.Microseconds())
+
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+
+	h.counts[i]++
+
+	if h.total == 0 || v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+
+	h.total++
+	h.sum += v
+}
+
+// merge adds the values recorded by o.
+func (h *histogram) merge(o *histogram) {
+	if o.total == 0 {
+		return
+	}
+
+	if len(o.counts) > len(h.counts) {
+		counts := make([]int64, len(o.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+
+	if h.total == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+
+	h.total += o.total
+	h.sum += o.sum
+}
+
+// quantile returns the value below which a fraction q of the values are.
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := max(1, int64(math.Ceil(q*float64(h.total))))
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			return time.Duration(min(bucketUpperBound(i), h.max)) * time.Microsecond
+		}
+	}
+
+	return time.Duration(h.max) * time.Microsecond
+}
+
+func (h *histogram) mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	return time.Duration(h.sum/h.total) * time.Microsecond
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		value     int64
+		wantIndex int
+		wantBound int64
+	}{
+		{value: 0, wantIndex: 0, wantBound: 0},
+		{value: 1, wantIndex: 1, wantBound: 1},
+		{value: 255, wantIndex: 255, wantBound: 255},
+		{value: 256, wantIndex: 256, wantBound: 257},
+		{value: 257, wantIndex: 256, wantBound: 257},
+		{value: 258, wantIndex: 257, wantBound: 259},
+		{value: 511, wantIndex: 383, wantBound: 511},
+		{value: 512, wantIndex: 384, wantBound: 515},
+	}
+
+	for _, tt := range tests {
+		i := bucketIndex(tt.value)
+		if i != tt.wantIndex {
+			t.Errorf("bucketIndex(%d) = %d, want %d", tt.value, i, tt.wantIndex)
+		}
+		if bound := bucketUpperBound(i); bound != tt.wantBound {
+			t.Errorf("bucketUpperBound(%d) = %d, want %d", i, bound, tt.wantBound)
+		}
+	}
+}
+
+// TestBucketPrecision checks that every value falls in a bucket bounded
+// within 1% above it, and that the buckets follow each other.
+func TestBucketPrecision(t *testing.T) {
+	for _, v := range []int64{1 << 10, 12345, 1 << 20, 987654321, int64(time.Hour / time.Microsecond)} {
+		for _, value := range []int64{v - 1, v, v + 1} {
+			i := bucketIndex(value)
+			bound := bucketUpperBound(i)
+
+			if bound < value || float64(bound-value) > float64(value)/(1<<subBucketBits) {
+				t.Errorf("value %d is in bucket %d bounded by %d, want within 1%%", value, i, bound)
+			}
+			if bucketIndex(bucketUpperBound(i-1)+1) != i {
+				t.Errorf("bucket %d does not start after the bound of bucket %d", i, i-1)
+			}
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var uniform histogram
+	for ms := 1; ms <= 100; ms++ {
+		uniform.record(time.Duration(ms) * time.Millisecond)
+	}
+
+	var single histogram
+	single.record(1234567 * time.Microsecond)
+
+	tests := []struct {
+		name string
+		h    *histogram
+		q    float64
+		want time.Duration
+	}{
+		{name: "empty", h: &histogram{}, q: 0.5, want: 0},
+		{name: "zero quantile is the min", h: &uniform, q: 0, want: time.Millisecond},
+		{name: "median", h: &uniform, q: 0.5, want: 50 * time.Millisecond},
+		{name: "p99", h: &uniform, q: 0.99, want: 99 * time.Millisecond},
+		{name: "max", h: &uniform, q: 1, want: 100 * time.Millisecond},
+		{name: "single value is exact", h: &single, q: 0.5, want: 1234567 * time.Microsecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.h.quantile(tt.q)
+
+			// Quantiles are bucket bounds, at most 1% above the value.
+			if got < tt.want || float64(got-tt.want) > float64(tt.want)/100 {
+				t.Errorf("quantile(%v) = %s, want %s within 1%%", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	var a, b, all histogram
+	for ms := 1; ms <= 100; ms++ {
+		d := time.Duration(ms) * time.Millisecond
+		if ms%2 == 0 {
+			a.record(d)
+		} else {
+			b.record(d)
+		}
+		all.record(d)
+	}
+
+	a.merge(&b)
+	a.merge(&histogram{})
+
+	if a.total != all.total || a.min != all.min || a.max != all.max || a.mean() != all.mean() {
+		t.Errorf("merged total, min, max, mean = %d, %d, %d, %s, want %d, %d, %d, %s",
+			a.total, a.min, a.max, a.mean(), all.total, all.min, all.max, all.mean())
+	}
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		if a.quantile(q) != all.quantile(q) {
+			t.Errorf("merged quantile(%v) = %s, want %s", q, a.quantile(q), all.quantile(q))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	distributionUniform    = "uniform"
+	distributionZipf       = "zipf"
+	distributionList       = "list"
+	distributionSequential = "sequential"
+
+	// zipfExponent skews the zipf distribution towards the lowest IDs.
+	zipfExponent = 1.1
+)
+
+// paramSpec describes the values of a path parameter: drawn between Min and
+// Max with the uniform and zipf distributions, cycled through from Min to Max
+// with sequential, or drawn from Values with list.
+type paramSpec struct {
+	Distribution string `yaml:"distribution"`
+	Min          int    `yaml:"min"`
+	Max          int    `yaml:"max"`
+	Values       []int  `yaml:"values"`
+}
+
+func (p paramSpec) validate() error {
+	switch p.Distribution {
+	case distributionUniform, distributionZipf, distributionSequential:
+		if p.Min < 1 || p.Max < p.Min {
+			return fmt.Errorf("min and max must satisfy 1 <= min <= max, got %d and %d", p.Min, p.Max)
+		}
+		if len(p.Values) > 0 {
+			return fmt.Errorf("values are only used by the %s distribution", distributionList)
+		}
+	case distributionList:
+		if len(p.Values) == 0 {
+			return errors.New("values must not be empty")
+		}
+		if p.Min != 0 || p.Max != 0 {
+			return fmt.Errorf("min and max are not used by the %s distribution", distributionList)
+		}
+	default:
+		return fmt.Errorf("distribution must be one of %s, %s, %s, %s, got %q",
+			distributionUniform, distributionZipf, distributionList, distributionSequential, p.Distribution)
+	}
+
+	return nil
+}
+
+// idGenerator returns the IDs to request. It is not safe for concurrent use.
+type idGenerator func() int
+
+func newIDGenerator(p paramSpec) idGenerator {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	switch p.Distribution {
+	case distributionZipf:
+		zipf := rand.NewZipf(r, zipfExponent, 1, uint64(p.Max-p.Min))
+		return func() int {
+			return p.Min + int(zipf.Uint64())
+		}
+	case distributionList:
+		return func() int {
+			return p.Values[r.Intn(len(p.Values))]
+		}
+	case distributionSequential:
+		next := p.Min
+		return func() int {
+			id := next
+			if next++; next > p.Max {
+				next = p.Min
+			}
+			return id
+		}
+	default:
+		return func() int {
+			return p.Min + r.Intn(p.Max-p.Min+1)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIDGeneratorSequence(t *testing.T) {
+	tests := []struct {
+		name string
+		spec paramSpec
+		want []int
+	}{
+		{
+			name: "sequential wraps around",
+			spec: paramSpec{Distribution: distributionSequential, Min: 3, Max: 5},
+			want: []int{3, 4, 5, 3, 4, 5, 3},
+		},
+		{
+			name: "sequential single value",
+			spec: paramSpec{Distribution: distributionSequential, Min: 2, Max: 2},
+			want: []int{2, 2, 2},
+		},
+		{
+			name: "list of one value",
+			spec: paramSpec{Distribution: distributionList, Values: []int{42}},
+			want: []int{42, 42, 42},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := newIDGenerator(tt.spec)
+
+			got := make([]int, len(tt.want))
+			for i := range got {
+				got[i] = gen()
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("IDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIDGeneratorBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		spec paramSpec
+		want []int
+	}{
+		{
+			name: "uniform",
+			spec: paramSpec{Distribution: distributionUniform, Min: 1, Max: 3},
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "zipf",
+			spec: paramSpec{Distribution: distributionZipf, Min: 10, Max: 12},
+			want: []int{10, 11, 12},
+		},
+		{
+			name: "list",
+			spec: paramSpec{Distribution: distributionList, Values: []int{7, 9, 7}},
+			want: []int{7, 9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := newIDGenerator(tt.spec)
+
+			seen := make(map[int]bool)
+			for i := 0; i < 10000; i++ {
+				id := gen()
+				if !slices.Contains(tt.want, id) {
+					t.Fatalf("generated ID %d, want one of %v", id, tt.want)
+				}
+				seen[id] = true
+			}
+
+			for _, id := range tt.want {
+				if !seen[id] {
+					t.Errorf("ID %d was never generated", id)
+				}
+			}
+		})
+	}
+}
+
+func TestParamSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    paramSpec
+		wantErr bool
+	}{
+		{name: "uniform", spec: paramSpec{Distribution: distributionUniform, Min: 1, Max: 5}},
+		{name: "list", spec: paramSpec{Distribution: distributionList, Values: []int{1}}},
+		{name: "min above max", spec: paramSpec{Distribution: distributionSequential, Min: 5, Max: 1}, wantErr: true},
+		{name: "zero min", spec: paramSpec{Distribution: distributionZipf, Max: 1}, wantErr: true},
+		{name: "values without list", spec: paramSpec{Distribution: distributionUniform, Min: 1, Max: 5, Values: []int{1}}, wantErr: true},
+		{name: "empty list", spec: paramSpec{Distribution: distributionList}, wantErr: true},
+		{name: "list with range", spec: paramSpec{Distribution: distributionList, Values: []int{1}, Max: 5}, wantErr: true},
+		{name: "unknown distribution", spec: paramSpec{Distribution: "normal", Min: 1, Max: 5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
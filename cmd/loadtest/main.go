@@ -1,61 +1,48 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"math/rand"
 	"net/http"
-	"sync"
+	"os"
 
-	"go.uber.org/ratelimit"
+	"github.com/theskch/prometheus-issue/pkg/api"
 )
 
-const url = "http://localhost:8080/v1/info"
-
 const (
 	maxIdleConnsPerHost = 100
 )
 
 func main() {
+	opts, err := parseOptions(os.Args[0], os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
 
-	httpClient := http.Client{
+	httpClient := &http.Client{
+		Timeout: opts.timeout,
 		Transport: &http.Transport{
 			MaxIdleConnsPerHost: maxIdleConnsPerHost,
 		},
 	}
 
-	rl := ratelimit.New(2)
-	var wg sync.WaitGroup
-	for i := 0; i < 10; i++ {
-		rl.Take()
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			id := pickRandom([]int{1, 2, 3, 4, 5})
-			if err := makeRequest(httpClient, id); err != nil {
-				fmt.Printf("error: %s\n", err)
-			}
-		}()
-	}
-	wg.Wait()
-
-	fmt.Println("DONE!")
-}
-
-func pickRandom(numbers []int) int {
-	randomIndex := rand.Intn(len(numbers))
-
-	return numbers[randomIndex]
-}
-
-func makeRequest(client http.Client, id int) error {
-	resp, err := http.Get(fmt.Sprintf("%s/%d", url, id))
+	client, err := api.NewClient(opts.baseURL, api.WithHTTPClient(httpClient))
 	if err != nil {
-		return err
+		fmt.Fprintf(os.Stderr, "create client: %s\n", err)
+		os.Exit(1)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("status code not 200: %d", resp.StatusCode)
-	}
+	rep := run(opts, client)
+	rep.print(os.Stdout)
 
-	return nil
+	if opts.out != "" {
+		if err := rep.writeJSON(opts.out); err != nil {
+			fmt.Fprintf(os.Stderr, "write report: %s\n", err)
+			os.Exit(1)
+		}
+	}
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+const (
+	operationInfo = "info"
+	operationPing = "ping"
+)
+
+// endpoint sends an operation of the API with the values of its path
+// parameters.
+type endpoint struct {
+	params []string
+	send   func(ctx context.Context, c api.ClientInterface, params map[string]int, editors ...api.RequestEditorFn) (*http.Response, error)
+}
+
+// endpoints are the operations of api.ClientInterface, keyed by their
+// OpenAPI operation ID.
+var endpoints = map[string]endpoint{
+	operationInfo: {
+		params: []string{"id"},
+		send: func(ctx context.Context, c api.ClientInterface, params map[string]int, editors ...api.RequestEditorFn) (*http.Response, error) {
+			return c.Info(ctx, params["id"], editors...)
+		},
+	},
+	operationPing: {
+		send: func(ctx context.Context, c api.ClientInterface, _ map[string]int, editors ...api.RequestEditorFn) (*http.Response, error) {
+			return c.Ping(ctx, editors...)
+		},
+	},
+}
+
+// operation is sent in a share of Weight out of the weights of all
+// operations, with its path parameters generated following Params and with
+// Headers set. Name tells operations apart in the report, and defaults to
+// Operation.
+//
+//	operations:
+//	  - operation: info
+//	    weight: 3
+//	    params:
+//	      id:
+//	        distribution: zipf
+//	        min: 1
+//	        max: 1000
+//	    headers:
+//	      X-API-Key: loadtest
+//	  - operation: ping
+//	    weight: 1
+type operation struct {
+	Name      string               `yaml:"name"`
+	Operation string               `yaml:"operation"`
+	Weight    int                  `yaml:"weight"`
+	Params    map[string]paramSpec `yaml:"params"`
+	Headers   map[string]string    `yaml:"headers"`
+}
+
+// validate returns every invalid field of the operation, prefixed with its
+// name.
+func (op operation) validate() error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("operation %s: %w", op.Name, err))
+		}
+	}
+
+	if op.Weight <= 0 {
+		check(fmt.Errorf("weight must be positive, got %d", op.Weight))
+	}
+
+	ep, ok := endpoints[op.Operation]
+	if !ok {
+		check(fmt.Errorf("operation must be one of %s, got %q", strings.Join(operationIDs(), ", "), op.Operation))
+		return errors.Join(errs...)
+	}
+
+	for _, name := range ep.params {
+		p, ok := op.Params[name]
+		if !ok {
+			check(fmt.Errorf("missing param %s", name))
+			continue
+		}
+		if err := p.validate(); err != nil {
+			check(fmt.Errorf("param %s: %w", name, err))
+		}
+	}
+
+	for name := range op.Params {
+		if !slices.Contains(ep.params, name) {
+			check(fmt.Errorf("unknown param %s", name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func operationIDs() []string {
+	ids := make([]string, 0, len(endpoints))
+	for id := range endpoints {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// mix picks the operations to send by weight, and generates their
+// parameters. It is not safe for concurrent use.
+type mix struct {
+	rand       *rand.Rand
+	totals     []int
+	operations []preparedOperation
+}
+
+type preparedOperation struct {
+	endpoint endpoint
+	params   map[string]idGenerator
+	editors  []api.RequestEditorFn
+}
+
+func newMix(operations []operation) *mix {
+	m := &mix{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	total := 0
+	for _, op := range operations {
+		total += op.Weight
+		m.totals = append(m.totals, total)
+
+		prepared := preparedOperation{
+			endpoint: endpoints[op.Operation],
+			params:   make(map[string]idGenerator, len(op.Params)),
+		}
+		for name, p := range op.Params {
+			prepared.params[name] = newIDGenerator(p)
+		}
+		if len(op.Headers) > 0 {
+			prepared.editors = append(prepared.editors, setHeaders(op.Headers))
+		}
+
+		m.operations = append(m.operations, prepared)
+	}
+
+	return m
+}
+
+// next returns the index of the operation to send, and the values of its
+// path parameters.
+func (m *mix) next() (int, map[string]int) {
+	i := 0
+	if len(m.operations) > 1 {
+		i = sort.SearchInts(m.totals, m.rand.Intn(m.totals[len(m.totals)-1])+1)
+	}
+
+	params := make(map[string]int, len(m.operations[i].params))
+	for name, gen := range m.operations[i].params {
+		params[name] = gen()
+	}
+
+	return i, params
+}
+
+func setHeaders(headers map[string]string) api.RequestEditorFn {
+	return func(_ context.Context, req *http.Request) error {
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+// recordingClient records the operations sent through api.ClientInterface.
+type recordingClient struct {
+	api.ClientInterface
+	calls []string
+	ids   []int
+}
+
+func (c *recordingClient) Info(_ context.Context, id int, _ ...api.RequestEditorFn) (*http.Response, error) {
+	c.calls = append(c.calls, operationInfo)
+	c.ids = append(c.ids, id)
+
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func (c *recordingClient) Ping(context.Context, ...api.RequestEditorFn) (*http.Response, error) {
+	c.calls = append(c.calls, operationPing)
+
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+// TestEndpointsCoverClient fails when a method is added to the client
+// without an endpoint sending it.
+func TestEndpointsCoverClient(t *testing.T) {
+	client := reflect.TypeOf((*api.ClientInterface)(nil)).Elem()
+	for i := 0; i < client.NumMethod(); i++ {
+		name := client.Method(i).Name
+		if id := strings.ToLower(name[:1]) + name[1:]; endpoints[id].send == nil {
+			t.Errorf("no endpoint for operation %s of method %s", id, name)
+		}
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	tests := []struct {
+		operation  string
+		params     map[string]int
+		wantParams []string
+		wantIDs    []int
+	}{
+		{operation: operationInfo, params: map[string]int{"id": 7}, wantParams: []string{"id"}, wantIDs: []int{7}},
+		{operation: operationPing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			ep, ok := endpoints[tt.operation]
+			if !ok {
+				t.Fatalf("no endpoint for %s in %v", tt.operation, operationIDs())
+			}
+			if !slices.Equal(ep.params, tt.wantParams) {
+				t.Errorf("params = %v, want %v", ep.params, tt.wantParams)
+			}
+
+			c := &recordingClient{}
+			resp, err := ep.send(context.Background(), c, tt.params)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("send() = %v, %v", resp, err)
+			}
+			if !slices.Equal(c.calls, []string{tt.operation}) || !slices.Equal(c.ids, tt.wantIDs) {
+				t.Errorf("sent %v with IDs %v, want %s with IDs %v", c.calls, c.ids, tt.operation, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestMixNext(t *testing.T) {
+	const draws = 10000
+
+	tests := []struct {
+		name       string
+		operations []operation
+		want       []float64
+	}{
+		{
+			name:       "single operation",
+			operations: []operation{{Operation: operationPing, Weight: 5}},
+			want:       []float64{1},
+		},
+		{
+			name: "equal weights",
+			operations: []operation{
+				{Operation: operationPing, Weight: 1},
+				{Operation: operationPing, Weight: 1},
+			},
+			want: []float64{0.5, 0.5},
+		},
+		{
+			name: "skewed weights",
+			operations: []operation{
+				{Operation: operationPing, Weight: 1},
+				{Operation: operationPing, Weight: 3},
+				{Operation: operationPing, Weight: 6},
+			},
+			want: []float64{0.1, 0.3, 0.6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMix(tt.operations)
+			m.rand = rand.New(rand.NewSource(1))
+
+			counts := make([]int, len(tt.operations))
+			for i := 0; i < draws; i++ {
+				op, _ := m.next()
+				counts[op]++
+			}
+
+			for i, want := range tt.want {
+				if share := float64(counts[i]) / draws; math.Abs(share-want) > 0.02 {
+					t.Errorf("operation %d was picked %.3f of the time, want %.3f", i, share, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMixNextParams(t *testing.T) {
+	m := newMix([]operation{{
+		Operation: operationInfo,
+		Weight:    1,
+		Params:    map[string]paramSpec{"id": {Distribution: distributionSequential, Min: 4, Max: 5}},
+	}})
+
+	var ids []int
+	for i := 0; i < 3; i++ {
+		op, params := m.next()
+		if op != 0 {
+			t.Fatalf("next() picked operation %d, want 0", op)
+		}
+		ids = append(ids, params["id"])
+	}
+
+	if want := []int{4, 5, 4}; !slices.Equal(ids, want) {
+		t.Errorf("IDs = %v, want %v", ids, want)
+	}
+}
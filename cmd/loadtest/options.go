@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL     = "http://localhost:8080/v1"
+	defaultRate        = 2
+	defaultRequests    = 10
+	defaultConcurrency = 100
+	defaultIDs         = "1-5"
+	defaultTimeout     = 5 * time.Second
+)
+
+// options configures a load test run. Unless a scenario file is given, the
+// run is a single constant stage lasting duration when set and otherwise
+// until requests were sent. Unless the scenario defines operations, the run
+// sends the info operation with IDs between minID and maxID.
+type options struct {
+	baseURL     string
+	rate        int
+	duration    time.Duration
+	requests    int
+	concurrency int
+	minID       int
+	maxID       int
+	distrib     string
+	timeout     time.Duration
+	model       string
+	out         string
+	scenario    string
+	stages      []stage
+	operations  []operation
+	headers     map[string]string
+}
+
+// parseOptions parses the command-line flags. Invalid ones are reported
+// along with the usage message.
+func parseOptions(name string, args []string, output io.Writer) (options, error) {
+	var o options
+	var ids string
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&o.baseURL, "url", defaultBaseURL, "base URL of the API")
+	fs.IntVar(&o.rate, "rate", defaultRate, "requests sent per second")
+	fs.DurationVar(&o.duration, "duration", 0, "how long to send requests for, exclusive with -requests")
+	fs.IntVar(&o.requests, "requests", 0, fmt.Sprintf("number of requests to send, exclusive with -duration (default %d)", defaultRequests))
+	fs.IntVar(&o.concurrency, "concurrency", defaultConcurrency, "maximum number of requests in flight")
+	fs.StringVar(&ids, "ids", defaultIDs, "range of the requested IDs, as min-max")
+	fs.StringVar(&o.distrib, "id-distribution", distributionUniform, "distribution of the requested IDs, one of uniform, zipf, sequential")
+	fs.DurationVar(&o.timeout, "timeout", defaultTimeout, "timeout of every request")
+	fs.StringVar(&o.model, "model", modelClosed, "load model, both waiting for a free slot to send a request: closed to measure latency from when requests are sent and skip the slots missed meanwhile, open to keep the schedule, measure latency from it and count late starts")
+	fs.StringVar(&o.out, "out", "", "file the report is written to as JSON")
+	fs.StringVar(&o.scenario, "scenario", "", "YAML file defining the stages of the run, exclusive with -rate, -duration and -requests")
+
+	if err := fs.Parse(args); err != nil {
+		return options{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return options{}, usageError(fs, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " ")))
+	}
+
+	errs := []error{o.validate()}
+
+	var err error
+	o.minID, o.maxID, err = parseRange(ids)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("-ids: %w", err))
+	}
+
+	if o.scenario != "" {
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "rate" || f.Name == "duration" || f.Name == "requests" {
+				errs = append(errs, fmt.Errorf("-scenario and -%s are exclusive", f.Name))
+			}
+		})
+
+		s, err := loadScenario(o.scenario)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-scenario: %w", err))
+		}
+
+		if len(s.Operations) > 0 {
+			fs.Visit(func(f *flag.Flag) {
+				if f.Name == "ids" || f.Name == "id-distribution" {
+					errs = append(errs, fmt.Errorf("-%s is not used by scenarios defining operations", f.Name))
+				}
+			})
+		}
+
+		o.stages = s.Stages
+		o.operations = s.Operations
+		o.headers = s.Headers
+	} else {
+		if o.duration == 0 && o.requests == 0 {
+			o.requests = defaultRequests
+		}
+
+		o.stages = []stage{{
+			Name:     profileConstant,
+			Profile:  profileConstant,
+			Rate:     float64(o.rate),
+			Duration: o.duration,
+			Requests: o.requests,
+		}}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return options{}, usageError(fs, err)
+	}
+
+	if len(o.operations) == 0 {
+		o.operations = []operation{{
+			Name:      operationInfo,
+			Operation: operationInfo,
+			Weight:    1,
+			Params: map[string]paramSpec{
+				"id": {Distribution: o.distrib, Min: o.minID, Max: o.maxID},
+			},
+			Headers: o.headers,
+		}}
+	}
+
+	return o, nil
+}
+
+func (o options) validate() error {
+	var errs []error
+
+	u, err := url.Parse(o.baseURL)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("-url: %w", err))
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("-url must be an absolute http or https URL, got %q", o.baseURL))
+	}
+
+	if o.rate <= 0 {
+		errs = append(errs, fmt.Errorf("-rate must be positive, got %d", o.rate))
+	}
+
+	if o.duration < 0 {
+		errs = append(errs, fmt.Errorf("-duration must not be negative, got %s", o.duration))
+	}
+
+	if o.requests < 0 {
+		errs = append(errs, fmt.Errorf("-requests must not be negative, got %d", o.requests))
+	}
+
+	if o.duration > 0 && o.requests > 0 {
+		errs = append(errs, errors.New("-duration and -requests are exclusive"))
+	}
+
+	if o.concurrency <= 0 {
+		errs = append(errs, fmt.Errorf("-concurrency must be positive, got %d", o.concurrency))
+	}
+
+	if o.distrib != distributionUniform && o.distrib != distributionZipf && o.distrib != distributionSequential {
+		errs = append(errs, fmt.Errorf("-id-distribution must be %s, %s or %s, got %q",
+			distributionUniform, distributionZipf, distributionSequential, o.distrib))
+	}
+
+	if o.model != modelClosed && o.model != modelOpen {
+		errs = append(errs, fmt.Errorf("-model must be %s or %s, got %q", modelClosed, modelOpen, o.model))
+	}
+
+	if o.timeout <= 0 {
+		errs = append(errs, fmt.Errorf("-timeout must be positive, got %s", o.timeout))
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseRange parses an inclusive range of positive integers, such as 1-5.
+// A single number is a range of one.
+func parseRange(s string) (int, int, error) {
+	lo, hi, found := strings.Cut(s, "-")
+	if !found {
+		hi = lo
+	}
+
+	first, err := strconv.Atoi(lo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+
+	last, err := strconv.Atoi(hi)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+
+	if first < 1 || last < first {
+		return 0, 0, fmt.Errorf("range %q must satisfy 1 <= min <= max", s)
+	}
+
+	return first, last, nil
+}
+
+// usageError prints err and the usage message, and returns err.
+func usageError(fs *flag.FlagSet, err error) error {
+	fmt.Fprintf(fs.Output(), "%s\n", err)
+	fs.Usage()
+
+	return err
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantMin  int
+		wantMax  int
+		wantErr  bool
+		errorMsg string
+	}{
+		{input: "1-5", wantMin: 1, wantMax: 5},
+		{input: "3", wantMin: 3, wantMax: 3},
+		{input: "7-7", wantMin: 7, wantMax: 7},
+		{input: "", wantErr: true, errorMsg: "invalid range"},
+		{input: "a-5", wantErr: true, errorMsg: "invalid range"},
+		{input: "1-b", wantErr: true, errorMsg: "invalid range"},
+		{input: "1-", wantErr: true, errorMsg: "invalid range"},
+		{input: "1-2-3", wantErr: true, errorMsg: "invalid range"},
+		{input: "0-5", wantErr: true, errorMsg: "1 <= min <= max"},
+		{input: "5-1", wantErr: true, errorMsg: "1 <= min <= max"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			lo, hi, err := parseRange(tt.input)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("parseRange(%q) error = %v, want it to contain %q", tt.input, err, tt.errorMsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseRange(%q) error = %s", tt.input, err)
+			}
+			if lo != tt.wantMin || hi != tt.wantMax {
+				t.Errorf("parseRange(%q) = %d, %d, want %d, %d", tt.input, lo, hi, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	valid := options{
+		baseURL:     defaultBaseURL,
+		rate:        defaultRate,
+		requests:    defaultRequests,
+		concurrency: defaultConcurrency,
+		distrib:     distributionUniform,
+		timeout:     defaultTimeout,
+		model:       modelClosed,
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(*options)
+		errorMsg string
+	}{
+		{name: "valid", modify: func(*options) {}},
+		{name: "open model", modify: func(o *options) { o.model = modelOpen }},
+		{name: "duration", modify: func(o *options) { o.requests, o.duration = 0, time.Minute }},
+		{name: "relative url", modify: func(o *options) { o.baseURL = "/v1" }, errorMsg: "-url must be an absolute"},
+		{name: "unsupported scheme", modify: func(o *options) { o.baseURL = "ftp://localhost/v1" }, errorMsg: "-url must be an absolute"},
+		{name: "invalid url", modify: func(o *options) { o.baseURL = "http://%zz" }, errorMsg: "-url:"},
+		{name: "zero rate", modify: func(o *options) { o.rate = 0 }, errorMsg: "-rate must be positive"},
+		{name: "negative duration", modify: func(o *options) { o.requests, o.duration = 0, -time.Second }, errorMsg: "-duration must not be negative"},
+		{name: "negative requests", modify: func(o *options) { o.requests = -1 }, errorMsg: "-requests must not be negative"},
+		{name: "duration and requests", modify: func(o *options) { o.duration = time.Minute }, errorMsg: "exclusive"},
+		{name: "zero concurrency", modify: func(o *options) { o.concurrency = 0 }, errorMsg: "-concurrency must be positive"},
+		{name: "unknown distribution", modify: func(o *options) { o.distrib = "normal" }, errorMsg: "-id-distribution must be"},
+		{name: "unknown model", modify: func(o *options) { o.model = "half-open" }, errorMsg: "-model must be"},
+		{name: "zero timeout", modify: func(o *options) { o.timeout = 0 }, errorMsg: "-timeout must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := valid
+			tt.modify(&o)
+
+			err := o.validate()
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validate() error = %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validate() error = %v, want it to contain %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantRequests int
+		wantDuration time.Duration
+		wantMin      int
+		wantMax      int
+		wantErr      bool
+	}{
+		{
+			name:         "defaults",
+			wantRequests: defaultRequests,
+			wantMin:      1,
+			wantMax:      5,
+		},
+		{
+			name:         "duration replaces the default requests",
+			args:         []string{"-duration", "1m", "-ids", "2-9"},
+			wantDuration: time.Minute,
+			wantMin:      2,
+			wantMax:      9,
+		},
+		{
+			name:    "invalid ids",
+			args:    []string{"-ids", "9-2"},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected argument",
+			args:    []string{"extra"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := parseOptions("loadtest", tt.args, io.Discard)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("parseOptions() returned no error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseOptions() error = %s", err)
+			}
+			if o.minID != tt.wantMin || o.maxID != tt.wantMax {
+				t.Errorf("ids = %d-%d, want %d-%d", o.minID, o.maxID, tt.wantMin, tt.wantMax)
+			}
+			if len(o.stages) != 1 || o.stages[0].Requests != tt.wantRequests || o.stages[0].Duration != tt.wantDuration {
+				t.Errorf("stages = %+v, want one of %d requests lasting %s", o.stages, tt.wantRequests, tt.wantDuration)
+			}
+		})
+	}
+}
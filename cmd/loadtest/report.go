@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	errorTimeout           = "timeout"
+	errorConnectionRefused = "connection_refused"
+	errorConnectionReset   = "connection_reset"
+	errorEOF               = "eof"
+	errorOther             = "other"
+)
+
+// result is the outcome of a single request. Requests failing before a
+// response is received have no status code. startLag is how long after its
+// scheduled time the request was sent.
+type result struct {
+	latency    time.Duration
+	startLag   time.Duration
+	statusCode int
+	err        error
+}
+
+// recorder aggregates the results of the requests. It is safe for
+// concurrent use.
+type recorder struct {
+	m           sync.Mutex
+	latency     histogram
+	statusCodes map[int]int64
+	errorTypes  map[string]int64
+	lateStarts  int64
+	maxStartLag time.Duration
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		statusCodes: make(map[int]int64),
+		errorTypes:  make(map[string]int64),
+	}
+}
+
+func (r *recorder) record(res result) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.latency.record(res.latency)
+
+	if res.startLag > lateStartThreshold {
+		r.lateStarts++
+	}
+	r.maxStartLag = max(r.maxStartLag, res.startLag)
+
+	if res.err != nil {
+		r.errorTypes[errorType(res.err)]++
+		return
+	}
+
+	r.statusCodes[res.statusCode]++
+}
+
+// errorType classifies the errors of requests which got no response.
+func errorType(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errorConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return errorConnectionReset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errorEOF
+	default:
+		return errorOther
+	}
+}
+
+// report summarizes a load test run, each of its stages and each of its
+// operations.
+type report struct {
+	Model string `json:"model"`
+	summary
+	Stages     []stageReport     `json:"stages"`
+	Operations []operationReport `json:"operations"`
+}
+
+type stageReport struct {
+	Name    string `json:"name"`
+	Profile string `json:"profile"`
+	summary
+}
+
+type operationReport struct {
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	Weight    int    `json:"weight"`
+	summary
+}
+
+// summary describes the results of requests. Latencies are in milliseconds.
+type summary struct {
+	Requests     int64            `json:"requests"`
+	Errors       int64            `json:"errors"`
+	Duration     float64          `json:"duration_seconds"`
+	Throughput   float64          `json:"throughput_rps"`
+	Latency      latencyReport    `json:"latency_ms"`
+	StatusCodes  map[string]int64 `json:"status_codes"`
+	ErrorsByType map[string]int64 `json:"errors_by_type"`
+	LateStarts   int64            `json:"late_starts"`
+	MaxStartLag  float64          `json:"max_start_lag_ms"`
+}
+
+// newReport returns the report of a run which lasted elapsed.
+func newReport(model string, elapsed time.Duration, stages []stageRun, operations []operationRun) report {
+	rep := report{
+		Model: model,
+	}
+
+	all := newRecorder()
+	for _, sr := range stages {
+		all.merge(sr.results)
+		rep.Stages = append(rep.Stages, stageReport{
+			Name:    sr.stage.Name,
+			Profile: sr.stage.Profile,
+			summary: sr.results.summary(sr.end.Sub(sr.start)),
+		})
+	}
+
+	rep.summary = all.summary(elapsed)
+
+	for _, op := range operations {
+		rep.Operations = append(rep.Operations, operationReport{
+			Name:      op.operation.Name,
+			Operation: op.operation.Operation,
+			Weight:    op.operation.Weight,
+			summary:   op.results.summary(elapsed),
+		})
+	}
+
+	return rep
+}
+
+type latencyReport struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99_9"`
+	Max  float64 `json:"max"`
+}
+
+// merge adds the results recorded by o.
+func (r *recorder) merge(o *recorder) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.latency.merge(&o.latency)
+	for code, n := range o.statusCodes {
+		r.statusCodes[code] += n
+	}
+	for typ, n := range o.errorTypes {
+		r.errorTypes[typ] += n
+	}
+	r.lateStarts += o.lateStarts
+	r.maxStartLag = max(r.maxStartLag, o.maxStartLag)
+}
+
+// summary returns the summary of the results recorded over elapsed.
+// Responses other than 2xx count as errors.
+func (r *recorder) summary(elapsed time.Duration) summary {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	rep := summary{
+		Requests:     r.latency.total,
+		Duration:     elapsed.Seconds(),
+		Latency:      newLatencyReport(&r.latency),
+		StatusCodes:  make(map[string]int64, len(r.statusCodes)),
+		ErrorsByType: make(map[string]int64, len(r.errorTypes)),
+		LateStarts:   r.lateStarts,
+		MaxStartLag:  milliseconds(r.maxStartLag),
+	}
+
+	if elapsed > 0 {
+		rep.Throughput = float64(rep.Requests) / elapsed.Seconds()
+	}
+
+	for code, n := range r.statusCodes {
+		rep.StatusCodes[strconv.Itoa(code)] = n
+		if code < http.StatusOK || code >= http.StatusMultipleChoices {
+			rep.Errors += n
+		}
+	}
+
+	for typ, n := range r.errorTypes {
+		rep.ErrorsByType[typ] = n
+		rep.Errors += n
+	}
+
+	return rep
+}
+
+func newLatencyReport(h *histogram) latencyReport {
+	return latencyReport{
+		Min:  milliseconds(time.Duration(h.min) * time.Microsecond),
+		Mean: milliseconds(h.mean()),
+		P50:  milliseconds(h.quantile(0.5)),
+		P90:  milliseconds(h.quantile(0.9)),
+		P99:  milliseconds(h.quantile(0.99)),
+		P999: milliseconds(h.quantile(0.999)),
+		Max:  milliseconds(time.Duration(h.max) * time.Microsecond),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (rep report) print(w io.Writer) {
+	fmt.Fprintf(w, "Requests:    %d in %.2fs, %.2f/s\n", rep.Requests, rep.Duration, rep.Throughput)
+	fmt.Fprintf(w, "Errors:      %d\n", rep.Errors)
+	if rep.Model == modelOpen {
+		fmt.Fprintf(w, "Late starts: %d, up to %.2fms behind schedule\n", rep.LateStarts, rep.MaxStartLag)
+	}
+	fmt.Fprintf(w, "Latency:     min %.2fms, mean %.2fms, max %.2fms\n", rep.Latency.Min, rep.Latency.Mean, rep.Latency.Max)
+	fmt.Fprintf(w, "Percentiles: p50 %.2fms, p90 %.2fms, p99 %.2fms, p99.9 %.2fms\n",
+		rep.Latency.P50, rep.Latency.P90, rep.Latency.P99, rep.Latency.P999)
+
+	printCounts(w, "Status codes:", rep.StatusCodes)
+	printCounts(w, "Errors by type:", rep.ErrorsByType)
+
+	if len(rep.Stages) > 1 {
+		fmt.Fprintln(w, "Stages:")
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "  stage\tprofile\trequests\trate/s\terrors\tlate\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+		for _, st := range rep.Stages {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%.2f\t%d\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+				st.Name, st.Profile, st.Requests, st.Throughput, st.Errors, st.LateStarts,
+				st.Latency.P50, st.Latency.P90, st.Latency.P99, st.Latency.Max)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(rep.Operations) > 1 {
+		fmt.Fprintln(w, "Operations:")
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "  name\toperation\tweight\trequests\trate/s\terrors\tp50 ms\tp90 ms\tp99 ms\tmax ms\t")
+		for _, op := range rep.Operations {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%d\t%.2f\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+				op.Name, op.Operation, op.Weight, op.Requests, op.Throughput, op.Errors,
+				op.Latency.P50, op.Latency.P90, op.Latency.P99, op.Latency.Max)
+		}
+		_ = tw.Flush()
+	}
+}
+
+func printCounts(w io.Writer, title string, counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, title)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-20s %d\n", k, counts[k])
+	}
+}
+
+// writeJSON writes the report to the file at path.
+func (rep report) writeJSON(path string) error {
+	payload, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(payload, '\n'), 0o644)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+const (
+	// modelClosed measures latency from when requests are sent, skipping the
+	// slots missed while waiting for the server.
+	modelClosed = "closed"
+	// modelOpen keeps the schedule and measures latency from it, counting
+	// the requests that could not be sent on time.
+	modelOpen = "open"
+
+	// lateStartThreshold is how long after its scheduled time a request can
+	// be sent before it counts as late.
+	lateStartThreshold = time.Millisecond
+)
+
+type runner struct {
+	opts       options
+	client     api.ClientInterface
+	mix        *mix
+	operations []operationRun
+	slots      chan struct{}
+	wg         sync.WaitGroup
+}
+
+// run sends the requests of every stage and returns the report of the run.
+func run(opts options, client api.ClientInterface) report {
+	r := &runner{
+		opts:   opts,
+		client: client,
+		mix:    newMix(opts.operations),
+		slots:  make(chan struct{}, opts.concurrency),
+	}
+
+	for _, op := range opts.operations {
+		r.operations = append(r.operations, operationRun{
+			operation: op,
+			results:   newRecorder(),
+		})
+	}
+
+	stages := make([]stageRun, len(opts.stages))
+
+	start := time.Now()
+	stageStart := start
+	for i, st := range opts.stages {
+		stages[i] = stageRun{
+			stage:   st,
+			start:   stageStart,
+			results: newRecorder(),
+		}
+		stageStart = r.runStage(&stages[i])
+	}
+	r.wg.Wait()
+
+	return newReport(opts.model, time.Since(start), stages, r.operations)
+}
+
+// stageRun holds the results of a stage started at start.
+type stageRun struct {
+	stage   stage
+	start   time.Time
+	end     time.Time
+	results *recorder
+}
+
+// operationRun holds the results of an operation over the whole run.
+type operationRun struct {
+	operation operation
+	results   *recorder
+}
+
+// runStage sends the requests of a stage and returns when the next stage
+// starts.
+//
+// In the open model, requests are sent at the times scheduled from the start
+// of the stage and their latency is measured from that schedule, so that the
+// time requests spend waiting for a free slot while the server stalls is
+// accounted for, instead of being omitted. In the closed model, requests
+// delayed by the server are not caught up with and their latency is measured
+// from when they are actually sent.
+func (r *runner) runStage(sr *stageRun) time.Time {
+	st := sr.stage
+
+	offset := st.after(0, 0)
+	for sent := 0; st.Duration > 0 || sent < st.Requests; sent++ {
+		if now := time.Now(); r.opts.model == modelClosed && now.After(sr.start.Add(offset)) {
+			offset = st.after(now.Sub(sr.start), 0)
+		}
+
+		if st.Duration > 0 && offset >= st.Duration {
+			break
+		}
+
+		scheduled := sr.start.Add(offset)
+		time.Sleep(time.Until(scheduled))
+		if r.opts.model == modelOpen {
+			r.send(sr.results, scheduled)
+		} else {
+			r.send(sr.results, time.Time{})
+		}
+
+		offset = st.after(offset, 1)
+	}
+
+	sr.end = sr.start.Add(max(offset, st.Duration))
+	if now := time.Now(); r.opts.model == modelClosed && now.After(sr.end) {
+		sr.end = now
+	}
+
+	return sr.end
+}
+
+// send sends a request once a slot is free. A zero scheduled time means the
+// request has no schedule to keep.
+func (r *runner) send(results *recorder, scheduled time.Time) {
+	i, params := r.mix.next()
+	op := r.mix.operations[i]
+
+	r.slots <- struct{}{}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.slots }()
+
+		res := makeRequest(r.client, op, params, scheduled)
+		results.record(res)
+		r.operations[i].results.record(res)
+	}()
+}
+
+func makeRequest(client api.ClientInterface, op preparedOperation, params map[string]int, scheduled time.Time) result {
+	start := time.Now()
+
+	var res result
+	if !scheduled.IsZero() {
+		res.startLag = start.Sub(scheduled)
+		start = scheduled
+	}
+
+	resp, err := op.endpoint.send(context.Background(), client, params, op.editors...)
+	if err != nil {
+		res.latency = time.Since(start)
+		res.err = err
+
+		return res
+	}
+	defer resp.Body.Close()
+
+	// Draining the body lets the connection be reused.
+	_, res.err = io.Copy(io.Discard, resp.Body)
+	res.latency = time.Since(start)
+	res.statusCode = resp.StatusCode
+
+	return res
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+// stallingClient answers every ping after stall.
+type stallingClient struct {
+	api.ClientInterface
+	stall time.Duration
+}
+
+func (c stallingClient) Ping(context.Context, ...api.RequestEditorFn) (*http.Response, error) {
+	time.Sleep(c.stall)
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestRunModels(t *testing.T) {
+	const (
+		stall     = 20 * time.Millisecond
+		rate      = 200
+		duration  = 100 * time.Millisecond
+		scheduled = 20
+	)
+
+	runModel := func(model string) report {
+		return run(options{
+			concurrency: 1,
+			model:       model,
+			stages:      []stage{{Profile: profileConstant, Rate: rate, Duration: duration}},
+			operations:  []operation{{Name: operationPing, Operation: operationPing, Weight: 1}},
+		}, stallingClient{stall: stall})
+	}
+
+	t.Run(modelClosed, func(t *testing.T) {
+		rep := runModel(modelClosed)
+
+		// The slots missed while the server stalls are skipped, so about
+		// one request per stall is sent, and none of them late.
+		if most := int64(duration/stall) + 2; rep.Requests < 1 || rep.Requests > most {
+			t.Errorf("requests = %d, want between 1 and %d", rep.Requests, most)
+		}
+		if rep.LateStarts != 0 || rep.MaxStartLag != 0 {
+			t.Errorf("late starts = %d, max start lag = %vms, want none", rep.LateStarts, rep.MaxStartLag)
+		}
+		if limit := milliseconds(5 * stall); rep.Latency.Max >= limit {
+			t.Errorf("max latency = %vms, want the stall of the server, under %vms", rep.Latency.Max, limit)
+		}
+	})
+
+	t.Run(modelOpen, func(t *testing.T) {
+		rep := runModel(modelOpen)
+
+		// Every scheduled request is sent, each waiting for the ones before
+		// it, and its latency includes that wait.
+		if rep.Requests != scheduled {
+			t.Errorf("requests = %d, want %d", rep.Requests, scheduled)
+		}
+		if rep.LateStarts < scheduled/2 {
+			t.Errorf("late starts = %d, want at least %d", rep.LateStarts, scheduled/2)
+		}
+		if least := milliseconds(5 * stall); rep.MaxStartLag < least {
+			t.Errorf("max start lag = %vms, want at least %vms", rep.MaxStartLag, least)
+		}
+		if least := rep.MaxStartLag + milliseconds(stall); rep.Latency.Max < least {
+			t.Errorf("max latency = %vms, want at least the max start lag and the stall, %vms", rep.Latency.Max, least)
+		}
+	})
+}
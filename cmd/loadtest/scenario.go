@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	profileConstant = "constant"
+	profileRamp     = "ramp"
+	profileStep     = "step"
+	profileSine     = "sine"
+	profileSpike    = "spike"
+
+	// rateStep is how often the rate of a stage is evaluated while
+	// scheduling its requests.
+	rateStep = 10 * time.Millisecond
+)
+
+// scenario is a load test defined in a YAML file, whose stages are run one
+// after the other. Every request sends one of the operations, picked by
+// weight, with the headers of the scenario and those of the operation:
+//
+//	headers:
+//	  X-API-Key: loadtest
+//	operations:
+//	  - operation: info
+//	    weight: 9
+//	    params:
+//	      id:
+//	        distribution: uniform
+//	        min: 1
+//	        max: 100
+//	  - operation: ping
+//	    weight: 1
+//	stages:
+//	  - name: warmup
+//	    profile: constant
+//	    rate: 10
+//	    duration: 30s
+//	  - profile: ramp
+//	    from: 10
+//	    to: 100
+//	    duration: 1m
+//
+// Without operations, the IDs of the info operation are generated following
+// the -ids and -id-distribution flags.
+type scenario struct {
+	Headers    map[string]string `yaml:"headers"`
+	Operations []operation       `yaml:"operations"`
+	Stages     []stage           `yaml:"stages"`
+}
+
+// stage sends requests at a rate following its profile:
+//   - constant sends Rate requests per second.
+//   - ramp goes linearly from From to To requests per second.
+//   - step goes from From to To requests per second in Steps equal steps.
+//   - sine oscillates around Rate by Amplitude requests per second, every
+//     Period.
+//   - spike sends Rate requests per second, except for SpikeDuration from
+//     At, when it sends Peak requests per second.
+//
+// A stage lasts Duration, or, when it is zero, until Requests were sent.
+type stage struct {
+	Name     string        `yaml:"name"`
+	Profile  string        `yaml:"profile"`
+	Duration time.Duration `yaml:"duration"`
+	Requests int           `yaml:"-"`
+
+	Rate          float64       `yaml:"rate"`
+	From          float64       `yaml:"from"`
+	To            float64       `yaml:"to"`
+	Steps         int           `yaml:"steps"`
+	Amplitude     float64       `yaml:"amplitude"`
+	Period        time.Duration `yaml:"period"`
+	Peak          float64       `yaml:"peak"`
+	At            time.Duration `yaml:"at"`
+	SpikeDuration time.Duration `yaml:"spike_duration"`
+}
+
+// loadScenario reads and validates the scenario file at path. Stages
+// without a name are named after their profile and position, and operations
+// without one after their operation ID.
+func loadScenario(path string) (scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return scenario{}, err
+	}
+
+	var s scenario
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return scenario{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for i := range s.Stages {
+		if s.Stages[i].Name == "" {
+			s.Stages[i].Name = fmt.Sprintf("%d-%s", i+1, s.Stages[i].Profile)
+		}
+	}
+
+	for i := range s.Operations {
+		op := &s.Operations[i]
+		if op.Name == "" {
+			op.Name = op.Operation
+		}
+
+		headers := make(map[string]string, len(s.Headers)+len(op.Headers))
+		for name, value := range s.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		for name, value := range op.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		op.Headers = headers
+	}
+
+	if err := s.validate(); err != nil {
+		return scenario{}, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s scenario) validate() error {
+	if len(s.Stages) == 0 {
+		return errors.New("no stages")
+	}
+
+	var errs []error
+	names := make(map[string]bool, len(s.Stages))
+	for _, st := range s.Stages {
+		if names[st.Name] {
+			errs = append(errs, fmt.Errorf("duplicate stage name %q", st.Name))
+		}
+		names[st.Name] = true
+
+		if err := st.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	names = make(map[string]bool, len(s.Operations))
+	for _, op := range s.Operations {
+		if names[op.Name] {
+			errs = append(errs, fmt.Errorf("duplicate operation name %q", op.Name))
+		}
+		names[op.Name] = true
+
+		if err := op.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate returns every invalid field of the stage, prefixed with its name.
+func (st stage) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("stage %s: %s", st.Name, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	if st.Requests > 0 {
+		check(st.Duration == 0, "duration and requests are exclusive")
+	} else {
+		check(st.Duration > 0, "duration must be positive, got %s", st.Duration)
+	}
+
+	switch st.Profile {
+	case profileConstant:
+		check(st.Rate > 0, "rate must be positive, got %v", st.Rate)
+	case profileRamp:
+		check(st.From >= 0 && st.To >= 0 && st.From+st.To > 0, "from and to must not be negative nor both zero, got %v and %v", st.From, st.To)
+	case profileStep:
+		check(st.From >= 0 && st.To >= 0 && st.From+st.To > 0, "from and to must not be negative nor both zero, got %v and %v", st.From, st.To)
+		check(st.Steps >= 2, "steps must be at least 2, got %d", st.Steps)
+	case profileSine:
+		check(st.Rate > 0, "rate must be positive, got %v", st.Rate)
+		check(st.Amplitude >= 0 && st.Amplitude <= st.Rate, "amplitude must be between 0 and the rate, got %v", st.Amplitude)
+		check(st.Period > 0, "period must be positive, got %s", st.Period)
+	case profileSpike:
+		check(st.Rate > 0, "rate must be positive, got %v", st.Rate)
+		check(st.Peak > 0, "peak must be positive, got %v", st.Peak)
+		check(st.At >= 0 && st.At < st.Duration, "at must be within the duration, got %s", st.At)
+		check(st.SpikeDuration > 0, "spike duration must be positive, got %s", st.SpikeDuration)
+	default:
+		check(false, "profile must be one of %s, %s, %s, %s, %s, got %q",
+			profileConstant, profileRamp, profileStep, profileSine, profileSpike, st.Profile)
+	}
+
+	return errors.Join(errs...)
+}
+
+// rateAt returns the requests per second the stage sends at t from its start.
+func (st stage) rateAt(t time.Duration) float64 {
+	progress := 0.0
+	if st.Duration > 0 {
+		progress = min(1, float64(t)/float64(st.Duration))
+	}
+
+	switch st.Profile {
+	case profileRamp:
+		return st.From + (st.To-st.From)*progress
+	case profileStep:
+		step := min(float64(st.Steps-1), math.Floor(progress*float64(st.Steps)))
+		return st.From + (st.To-st.From)*step/float64(st.Steps-1)
+	case profileSine:
+		return st.Rate + st.Amplitude*math.Sin(2*math.Pi*float64(t)/float64(st.Period))
+	case profileSpike:
+		if t >= st.At && t < st.At+st.SpikeDuration {
+			return st.Peak
+		}
+		return st.Rate
+	default:
+		return st.Rate
+	}
+}
+
+// after returns the offset from the start of the stage at which requests
+// more requests are due after offset, by the integral of the rate of the
+// stage. The rate is taken as constant over every rateStep. The offset is not
+// moved past the end of the stage.
+func (st stage) after(offset time.Duration, requests float64) time.Duration {
+	for st.Duration == 0 || offset < st.Duration {
+		rate := st.rateAt(offset)
+		if rate > 0 {
+			if d := time.Duration(requests / rate * float64(time.Second)); d <= rateStep {
+				return offset + d
+			}
+			requests -= rate * rateStep.Seconds()
+		}
+
+		offset += rateStep
+	}
+
+	return st.Duration
+}
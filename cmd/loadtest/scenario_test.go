@@ -0,0 +1,163 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageRateAt(t *testing.T) {
+	constant := stage{Profile: profileConstant, Rate: 10, Duration: 10 * time.Second}
+	ramp := stage{Profile: profileRamp, From: 10, To: 30, Duration: 10 * time.Second}
+	rampDown := stage{Profile: profileRamp, From: 30, To: 0, Duration: 10 * time.Second}
+	step := stage{Profile: profileStep, From: 10, To: 40, Steps: 4, Duration: 4 * time.Second}
+	sine := stage{Profile: profileSine, Rate: 10, Amplitude: 5, Period: 4 * time.Second, Duration: time.Minute}
+	spike := stage{Profile: profileSpike, Rate: 10, Peak: 100, At: 2 * time.Second, SpikeDuration: time.Second, Duration: 10 * time.Second}
+
+	tests := []struct {
+		name  string
+		stage stage
+		at    time.Duration
+		want  float64
+	}{
+		{name: "constant", stage: constant, at: 3 * time.Second, want: 10},
+		{name: "constant without duration", stage: stage{Profile: profileConstant, Rate: 10, Requests: 5}, at: time.Hour, want: 10},
+		{name: "ramp start", stage: ramp, at: 0, want: 10},
+		{name: "ramp middle", stage: ramp, at: 5 * time.Second, want: 20},
+		{name: "ramp end", stage: ramp, at: 10 * time.Second, want: 30},
+		{name: "ramp past end", stage: ramp, at: 20 * time.Second, want: 30},
+		{name: "ramp down", stage: rampDown, at: 5 * time.Second, want: 15},
+		{name: "first step", stage: step, at: 999 * time.Millisecond, want: 10},
+		{name: "second step", stage: step, at: time.Second, want: 20},
+		{name: "third step", stage: step, at: 2500 * time.Millisecond, want: 30},
+		{name: "last step", stage: step, at: 3 * time.Second, want: 40},
+		{name: "last step at end", stage: step, at: 4 * time.Second, want: 40},
+		{name: "sine start", stage: sine, at: 0, want: 10},
+		{name: "sine crest", stage: sine, at: time.Second, want: 15},
+		{name: "sine middle", stage: sine, at: 2 * time.Second, want: 10},
+		{name: "sine trough", stage: sine, at: 3 * time.Second, want: 5},
+		{name: "sine next period", stage: sine, at: 5 * time.Second, want: 15},
+		{name: "before spike", stage: spike, at: 2*time.Second - time.Millisecond, want: 10},
+		{name: "spike start", stage: spike, at: 2 * time.Second, want: 100},
+		{name: "spike end", stage: spike, at: 3*time.Second - time.Millisecond, want: 100},
+		{name: "after spike", stage: spike, at: 3 * time.Second, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stage.rateAt(tt.at); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("rateAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage stage
+		want  int
+	}{
+		{name: "constant", stage: stage{Profile: profileConstant, Rate: 10, Duration: 10 * time.Second}, want: 100},
+		{name: "fast constant", stage: stage{Profile: profileConstant, Rate: 1000, Duration: time.Second}, want: 1000},
+		{name: "ramp up from zero", stage: stage{Profile: profileRamp, From: 0, To: 100, Duration: time.Minute}, want: 3000},
+		{name: "ramp down to zero", stage: stage{Profile: profileRamp, From: 100, To: 0, Duration: time.Minute}, want: 3000},
+		{name: "step", stage: stage{Profile: profileStep, From: 10, To: 40, Steps: 4, Duration: 4 * time.Second}, want: 100},
+		{name: "sine", stage: stage{Profile: profileSine, Rate: 100, Amplitude: 100, Period: 10 * time.Second, Duration: time.Minute}, want: 6000},
+		{name: "spike", stage: stage{Profile: profileSpike, Rate: 10, Peak: 100, At: 2 * time.Second, SpikeDuration: time.Second, Duration: 10 * time.Second}, want: 190},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := 0
+			for offset := tt.stage.after(0, 0); offset < tt.stage.Duration; offset = tt.stage.after(offset, 1) {
+				sent++
+			}
+
+			if math.Abs(float64(sent-tt.want)) > 2 {
+				t.Errorf("sent %d requests, want %d", sent, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageAfterRequests(t *testing.T) {
+	st := stage{Profile: profileConstant, Rate: 4, Requests: 3}
+
+	offset := st.after(0, 0)
+	for i, want := range []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond} {
+		if d := offset - want; d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("request %d sent at %s, want %s", i, offset, want)
+		}
+		offset = st.after(offset, 1)
+	}
+}
+
+func TestStageValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		stage    stage
+		errorMsg string
+	}{
+		{
+			name:  "constant",
+			stage: stage{Profile: profileConstant, Rate: 10, Duration: time.Second},
+		},
+		{
+			name:  "constant requests",
+			stage: stage{Profile: profileConstant, Rate: 10, Requests: 5},
+		},
+		{
+			name:     "duration and requests",
+			stage:    stage{Profile: profileConstant, Rate: 10, Duration: time.Second, Requests: 5},
+			errorMsg: "duration and requests are exclusive",
+		},
+		{
+			name:     "no duration",
+			stage:    stage{Profile: profileConstant, Rate: 10},
+			errorMsg: "duration must be positive",
+		},
+		{
+			name:     "ramp from and to zero",
+			stage:    stage{Profile: profileRamp, Duration: time.Second},
+			errorMsg: "from and to must not be negative nor both zero",
+		},
+		{
+			name:     "single step",
+			stage:    stage{Profile: profileStep, From: 1, To: 2, Steps: 1, Duration: time.Second},
+			errorMsg: "steps must be at least 2",
+		},
+		{
+			name:     "sine amplitude above rate",
+			stage:    stage{Profile: profileSine, Rate: 10, Amplitude: 11, Period: time.Second, Duration: time.Second},
+			errorMsg: "amplitude must be between 0 and the rate",
+		},
+		{
+			name:     "spike after the end",
+			stage:    stage{Profile: profileSpike, Rate: 10, Peak: 100, At: time.Minute, SpikeDuration: time.Second, Duration: time.Second},
+			errorMsg: "at must be within the duration",
+		},
+		{
+			name:     "unknown profile",
+			stage:    stage{Profile: "square", Duration: time.Second},
+			errorMsg: "profile must be one of",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.stage.validate()
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validate() error = %s", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validate() error = %v, want it to contain %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
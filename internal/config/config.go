@@ -0,0 +1,298 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"github.com/theskch/prometheus-issue/internal/service"
+)
+
+// Config is the configuration of the whole process. Every field can be set
+// from the YAML file, an environment variable and a command-line flag, see Load.
+type Config struct {
+	Log        Log        `yaml:"log"`
+	Service    Service    `yaml:"service"`
+	Monitoring Monitoring `yaml:"monitoring"`
+	Shutdown   Shutdown   `yaml:"shutdown"`
+}
+
+type Log struct {
+	Level string `yaml:"level" usage:"log level, one of trace, debug, info, warning, error"`
+}
+
+type Service struct {
+	Address           string                   `yaml:"address" usage:"address the service server listens on"`
+	ReadHeaderTimeout time.Duration            `yaml:"read_header_timeout" usage:"maximum duration for reading request headers"`
+	ReadTimeout       time.Duration            `yaml:"read_timeout" usage:"maximum duration for reading a whole request"`
+	WriteTimeout      time.Duration            `yaml:"write_timeout" usage:"maximum duration before timing out writes of the response"`
+	IdleTimeout       time.Duration            `yaml:"idle_timeout" usage:"maximum duration to wait for the next request on keep-alive connections"`
+	MaxHeaderBytes    int                      `yaml:"max_header_bytes" usage:"maximum size of request headers"`
+	MaxBodyBytes      int64                    `yaml:"max_body_bytes" usage:"maximum size of request bodies, 0 for unlimited"`
+	HandlerTimeout    time.Duration            `yaml:"handler_timeout" usage:"deadline of the api handlers, 0 to disable"`
+	RouteTimeouts     map[string]time.Duration `yaml:"route_timeouts" usage:"comma separated route=timeout handler deadlines overriding handler_timeout"`
+	AccessLog         AccessLog                `yaml:"access_log"`
+	RateLimit         RateLimit                `yaml:"rate_limit"`
+	ConcurrencyLimit  ConcurrencyLimit         `yaml:"concurrency_limit"`
+	APIExplorer       bool                     `yaml:"api_explorer" usage:"serve a page exploring the API at /v1/explorer"`
+	TLS               TLS                      `yaml:"tls"`
+}
+
+type AccessLog struct {
+	InformationalLevel string  `yaml:"informational_level" usage:"log level of 1xx responses"`
+	SuccessLevel       string  `yaml:"success_level" usage:"log level of 2xx responses"`
+	RedirectionLevel   string  `yaml:"redirection_level" usage:"log level of 3xx responses"`
+	ClientErrorLevel   string  `yaml:"client_error_level" usage:"log level of 4xx responses"`
+	ServerErrorLevel   string  `yaml:"server_error_level" usage:"log level of 5xx responses"`
+	SuccessSampleRate  float64 `yaml:"success_sample_rate" usage:"fraction of 2xx responses logged, between 0 and 1"`
+}
+
+// RateLimit configures the token buckets limiting the request rate of each
+// client and of all of them together. A zero rate disables the limit.
+type RateLimit struct {
+	Key         string   `yaml:"key" usage:"how clients are told apart, one of ip, api-key"`
+	APIKeys     []string `yaml:"api_keys" usage:"comma separated API keys limited separately with the api-key key, other requests are limited by IP"`
+	ClientRate  float64  `yaml:"client_rate" usage:"requests per second allowed per client, 0 to disable"`
+	ClientBurst int      `yaml:"client_burst" usage:"requests a client can make at once"`
+	MaxClients  int      `yaml:"max_clients" usage:"maximum number of clients tracked, the least recently seen ones are forgotten first"`
+	GlobalRate  float64  `yaml:"global_rate" usage:"requests per second allowed in total, 0 to disable"`
+	GlobalBurst int      `yaml:"global_burst" usage:"requests that can be made at once in total"`
+}
+
+// ConcurrencyLimit configures the number of requests served at once. In
+// adaptive mode the limit is tuned from the observed latency and errors.
+type ConcurrencyLimit struct {
+	Limit            int           `yaml:"limit" usage:"maximum number of requests served at once, initial one in adaptive mode, 0 to disable"`
+	Adaptive         bool          `yaml:"adaptive" usage:"tune the limit with additive increase, multiplicative decrease"`
+	MinLimit         int           `yaml:"min_limit" usage:"lowest adaptive limit"`
+	MaxLimit         int           `yaml:"max_limit" usage:"highest adaptive limit"`
+	LatencyThreshold time.Duration `yaml:"latency_threshold" usage:"latency above which the adaptive limit is decreased"`
+	BackoffRatio     float64       `yaml:"backoff_ratio" usage:"factor the adaptive limit is multiplied by when decreased"`
+}
+
+// TLS enables TLS when a certificate and key are set. Clients must present a
+// certificate signed by the client CA bundle when it is set; on the
+// monitoring server it is only required to scrape metrics.
+type TLS struct {
+	CertFile       string        `yaml:"cert_file" usage:"PEM certificate file, enables TLS"`
+	KeyFile        string        `yaml:"key_file" usage:"PEM private key file"`
+	ClientCAFile   string        `yaml:"client_ca_file" usage:"PEM CA bundle client certificates are verified against"`
+	ReloadInterval time.Duration `yaml:"reload_interval" usage:"how often the files are checked for changes"`
+}
+
+type Monitoring struct {
+	Address           string        `yaml:"address" usage:"address the monitoring server listens on"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" usage:"maximum duration for reading request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" usage:"maximum duration for reading a whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" usage:"maximum duration before timing out writes of the response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" usage:"maximum duration to wait for the next request on keep-alive connections"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" usage:"maximum size of request headers"`
+	TLS               TLS           `yaml:"tls"`
+	WebConfigFile     string        `yaml:"web_config_file" usage:"file listing the basic auth users and bearer tokens allowed to access the monitoring server"`
+	AuthExemptHealth  bool          `yaml:"auth_exempt_health" usage:"serve the health endpoints without authentication"`
+
+	DefaultRegistry bool             `yaml:"default_registry" usage:"expose metrics from the process-global prometheus registry"`
+	LatencyBuckets  LatencyBuckets   `yaml:"latency_buckets"`
+	IDLabel         IDLabel          `yaml:"id_label"`
+	Native          NativeHistograms `yaml:"native_histograms"`
+}
+
+type LatencyBuckets struct {
+	Preset  string    `yaml:"preset" usage:"latency bucket preset, one of default, db, api"`
+	Buckets []float64 `yaml:"buckets" usage:"comma separated latency buckets in seconds, overriding the preset"`
+}
+
+type NativeHistograms struct {
+	Enabled         bool    `yaml:"enabled" usage:"expose request latency as a native histogram"`
+	BucketFactor    float64 `yaml:"bucket_factor" usage:"growth factor between native histogram buckets"`
+	MaxBucketNumber uint32  `yaml:"max_bucket_number" usage:"maximum number of native histogram buckets"`
+}
+
+type IDLabel struct {
+	AllowList []string `yaml:"allow_list" usage:"comma separated IDs kept in the ID label, others are folded"`
+	MaxValues int      `yaml:"max_values" usage:"maximum number of distinct IDs tracked, the most requested ones, when no allow-list is set, 0 for unlimited"`
+}
+
+type Shutdown struct {
+	PreStopDelay      time.Duration `yaml:"pre_stop_delay" usage:"how long to keep serving after readiness turns failing"`
+	DrainTimeout      time.Duration `yaml:"drain_timeout" usage:"how long to wait for in-flight requests"`
+	FinalScrapeWindow time.Duration `yaml:"final_scrape_window" usage:"how long metrics stay up after draining"`
+	MonitoringTimeout time.Duration `yaml:"monitoring_timeout" usage:"how long to wait for the monitoring server to stop"`
+}
+
+func Default() Config {
+	return Config{
+		Log: Log{
+			Level: logrus.InfoLevel.String(),
+		},
+		Service: Service{
+			Address:           ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+			HandlerTimeout:    10 * time.Second,
+			TLS: TLS{
+				ReloadInterval: 10 * time.Second,
+			},
+			AccessLog: AccessLog{
+				InformationalLevel: logrus.InfoLevel.String(),
+				SuccessLevel:       logrus.InfoLevel.String(),
+				RedirectionLevel:   logrus.InfoLevel.String(),
+				ClientErrorLevel:   logrus.WarnLevel.String(),
+				ServerErrorLevel:   logrus.ErrorLevel.String(),
+				SuccessSampleRate:  1,
+			},
+			RateLimit: RateLimit{
+				Key:         string(service.RateLimitByIP),
+				ClientBurst: 10,
+				MaxClients:  10000,
+				GlobalBurst: 100,
+			},
+			ConcurrencyLimit: ConcurrencyLimit{
+				MinLimit:         1,
+				MaxLimit:         1000,
+				LatencyThreshold: time.Second,
+				BackoffRatio:     0.9,
+			},
+		},
+		Monitoring: Monitoring{
+			Address:           ":9090",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			TLS: TLS{
+				ReloadInterval: 10 * time.Second,
+			},
+			AuthExemptHealth: true,
+			LatencyBuckets: LatencyBuckets{
+				Preset: "db",
+			},
+			IDLabel: IDLabel{
+				MaxValues: 100,
+			},
+		},
+		Shutdown: Shutdown{
+			DrainTimeout:      5 * time.Second,
+			MonitoringTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// FieldError reports an invalid configuration field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate returns every invalid field of the configuration.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, &FieldError{Field: field, Err: err})
+		}
+	}
+
+	_, err := logrus.ParseLevel(c.Log.Level)
+	check("log.level", err)
+
+	check("service.address", validateAddress(c.Service.Address))
+	check("service.read_header_timeout", validateNotNegative(c.Service.ReadHeaderTimeout))
+	check("service.read_timeout", validatePositive(c.Service.ReadTimeout))
+	check("service.write_timeout", validateNotNegative(c.Service.WriteTimeout))
+	check("service.idle_timeout", validateNotNegative(c.Service.IdleTimeout))
+	check("service.max_header_bytes", validateNotNegativeSize(int64(c.Service.MaxHeaderBytes)))
+	check("service.max_body_bytes", validateNotNegativeSize(c.Service.MaxBodyBytes))
+	check("service.handler_timeout", validateNotNegative(c.Service.HandlerTimeout))
+	for route, timeout := range c.Service.RouteTimeouts {
+		check("service.route_timeouts."+route, validateNotNegative(timeout))
+	}
+	_, err = c.accessLogOptions()
+	check("service.access_log", err)
+	check("service.rate_limit", c.RateLimitOptions().Validate())
+	check("service.concurrency_limit", c.concurrencyLimitOptions().Validate())
+	check("service.tls", c.Service.TLS.options().Validate())
+
+	check("monitoring.address", validateAddress(c.Monitoring.Address))
+	if c.Monitoring.Address != "" && c.Monitoring.Address == c.Service.Address {
+		check("monitoring.address", errors.New("must differ from service.address"))
+	}
+
+	check("monitoring.read_header_timeout", validateNotNegative(c.Monitoring.ReadHeaderTimeout))
+	check("monitoring.read_timeout", validateNotNegative(c.Monitoring.ReadTimeout))
+	check("monitoring.write_timeout", validateNotNegative(c.Monitoring.WriteTimeout))
+	check("monitoring.idle_timeout", validateNotNegative(c.Monitoring.IdleTimeout))
+	check("monitoring.max_header_bytes", validateNotNegativeSize(int64(c.Monitoring.MaxHeaderBytes)))
+	check("monitoring.tls", c.Monitoring.TLS.options().Validate())
+
+	if len(c.Monitoring.LatencyBuckets.Buckets) == 0 {
+		if _, ok := monitoring.LatencyBucketPresets[c.Monitoring.LatencyBuckets.Preset]; !ok {
+			check("monitoring.latency_buckets.preset", fmt.Errorf("unknown preset %q", c.Monitoring.LatencyBuckets.Preset))
+		}
+	}
+
+	check("monitoring.latency_buckets", c.latencyHistogram().Validate())
+
+	if c.Monitoring.IDLabel.MaxValues < 0 {
+		check("monitoring.id_label.max_values", errors.New("must not be negative"))
+	}
+
+	check("shutdown.pre_stop_delay", validateNotNegative(c.Shutdown.PreStopDelay))
+	check("shutdown.drain_timeout", validatePositive(c.Shutdown.DrainTimeout))
+	check("shutdown.final_scrape_window", validateNotNegative(c.Shutdown.FinalScrapeWindow))
+	check("shutdown.monitoring_timeout", validatePositive(c.Shutdown.MonitoringTimeout))
+
+	return errors.Join(errs...)
+}
+
+func validateAddress(address string) error {
+	if address == "" {
+		return errors.New("must not be empty")
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validatePositive(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("must be positive, got %s", d)
+	}
+
+	return nil
+}
+
+func validateNotNegativeSize(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("must not be negative, got %d", n)
+	}
+
+	return nil
+}
+
+func validateNotNegative(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("must not be negative, got %s", d)
+	}
+
+	return nil
+}
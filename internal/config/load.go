@@ -0,0 +1,307 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix prefixes the environment variable of every field.
+	EnvPrefix = "APP_"
+
+	configFileFlag = "config"
+	configFileEnv  = EnvPrefix + "CONFIG_FILE"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML file named by -config or APP_CONFIG_FILE, the APP_
+// environment variables and the command-line flags, and validates it.
+//
+// A field such as service.read_timeout is set by the read_timeout key of the
+// service section of the file, by APP_SERVICE_READ_TIMEOUT and by
+// -service.read-timeout.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	fields := fieldsOf(&cfg)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String(configFileFlag, "", "path of the optional YAML configuration file, also read from "+configFileEnv)
+
+	flagValues := make(map[string]string)
+	for _, f := range fields {
+		f := f
+		usage := fmt.Sprintf("%s (env %s, default %q)", f.usage, f.env(), formatValue(f.value))
+		fs.Var(flagValue{
+			boolean: f.value.Kind() == reflect.Bool,
+			set: func(s string) error {
+				// Flags are parsed first to find the configuration file, but
+				// only applied once the file and the environment are read.
+				if err := setValue(reflect.New(f.value.Type()).Elem(), s); err != nil {
+					return err
+				}
+
+				flagValues[f.path] = s
+
+				return nil
+			},
+		}, f.flag(), usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(configFileEnv)
+	}
+
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range fields {
+		v, ok := lookupEnv(f.env())
+		if !ok {
+			continue
+		}
+
+		if err := setValue(f.value, v); err != nil {
+			return Config{}, &FieldError{Field: f.path, Err: fmt.Errorf("env %s: %w", f.env(), err)}
+		}
+	}
+
+	for _, f := range fields {
+		v, ok := flagValues[f.path]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(f.value, v); err != nil {
+			return Config{}, &FieldError{Field: f.path, Err: fmt.Errorf("flag -%s: %w", f.flag(), err)}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// loadFile reads the YAML configuration file on top of cfg. Unknown keys are
+// rejected.
+func loadFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// flagValue sets a configuration field from a flag. Flags of bool fields can
+// be given without a value, as with the flags of the standard library.
+type flagValue struct {
+	boolean bool
+	set     func(string) error
+}
+
+func (v flagValue) String() string {
+	return ""
+}
+
+func (v flagValue) Set(s string) error {
+	return v.set(s)
+}
+
+func (v flagValue) IsBoolFlag() bool {
+	return v.boolean
+}
+
+type field struct {
+	path  string
+	usage string
+	value reflect.Value
+}
+
+func (f field) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.path, ".", "_"))
+}
+
+func (f field) flag() string {
+	return strings.ReplaceAll(f.path, "_", "-")
+}
+
+// fieldsOf lists the settable leaf fields of cfg, named after their YAML keys.
+func fieldsOf(cfg *Config) []field {
+	var fields []field
+
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+
+			name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct {
+				walk(name, fv)
+				continue
+			}
+
+			fields = append(fields, field{
+				path:  name,
+				usage: sf.Tag.Get("usage"),
+				value: fv,
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+
+	return fields
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint32:
+		u, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := splitList(s)
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setValue(slice.Index(i), p); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, p := range splitList(s) {
+			key, value, ok := strings.Cut(p, "=")
+			if !ok {
+				return fmt.Errorf("%q is not a key=value pair", p)
+			}
+
+			mk := reflect.New(v.Type().Key()).Elem()
+			if err := setValue(mk, strings.TrimSpace(key)); err != nil {
+				return err
+			}
+
+			mv := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(mv, strings.TrimSpace(value)); err != nil {
+				return err
+			}
+
+			m.SetMapIndex(mk, mv)
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.Kind() == reflect.Slice {
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = formatValue(v.Index(i))
+		}
+
+		return strings.Join(parts, ",")
+	}
+
+	if v.Kind() == reflect.Map {
+		parts := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			parts = append(parts, formatValue(k)+"="+formatValue(v.MapIndex(k)))
+		}
+		sort.Strings(parts)
+
+		return strings.Join(parts, ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+func splitList(s string) []string {
+	var parts []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	return parts
+}
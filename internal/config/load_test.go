@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want func(*Config)
+	}{
+		{
+			name: "defaults",
+			want: func(*Config) {},
+		},
+		{
+			name: "file overrides defaults",
+			file: "service:\n  read_timeout: 3s\nlog:\n  level: debug\n",
+			want: func(c *Config) {
+				c.Service.ReadTimeout = 3 * time.Second
+				c.Log.Level = "debug"
+			},
+		},
+		{
+			name: "env overrides file",
+			file: "service:\n  read_timeout: 3s\n",
+			env:  map[string]string{"APP_SERVICE_READ_TIMEOUT": "4s"},
+			want: func(c *Config) {
+				c.Service.ReadTimeout = 4 * time.Second
+			},
+		},
+		{
+			name: "flags override env",
+			file: "service:\n  read_timeout: 3s\n",
+			env:  map[string]string{"APP_SERVICE_READ_TIMEOUT": "4s"},
+			args: []string{"-service.read-timeout", "5s"},
+			want: func(c *Config) {
+				c.Service.ReadTimeout = 5 * time.Second
+			},
+		},
+		{
+			name: "bool flag without value",
+			args: []string{"-monitoring.default-registry"},
+			want: func(c *Config) {
+				c.Monitoring.DefaultRegistry = true
+			},
+		},
+		{
+			name: "bool flag overrides env",
+			env:  map[string]string{"APP_MONITORING_DEFAULT_REGISTRY": "true"},
+			args: []string{"-monitoring.default-registry=false"},
+			want: func(*Config) {},
+		},
+		{
+			name: "comma separated list from env",
+			env:  map[string]string{"APP_MONITORING_ID_LABEL_ALLOW_LIST": "1,2,3"},
+			want: func(c *Config) {
+				c.Monitoring.IDLabel.AllowList = []string{"1", "2", "3"}
+			},
+		},
+		{
+			name: "config file from env",
+			file: "service:\n  address: \":8081\"\n",
+			want: func(c *Config) {
+				c.Service.Address = ":8081"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if tt.file != "" {
+				env = withEnv(env, configFileEnv, writeFile(t, tt.file))
+			}
+
+			got, err := Load("test", tt.args, lookup(env))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			want := Default()
+			tt.want(&want)
+
+			if got.Service.ReadTimeout != want.Service.ReadTimeout ||
+				got.Service.Address != want.Service.Address ||
+				got.Log.Level != want.Log.Level ||
+				got.Monitoring.DefaultRegistry != want.Monitoring.DefaultRegistry ||
+				!slices.Equal(got.Monitoring.IDLabel.AllowList, want.Monitoring.IDLabel.AllowList) {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		env       map[string]string
+		args      []string
+		wantField string
+	}{
+		{
+			name: "unknown file key",
+			file: "service:\n  unknown: 1\n",
+		},
+		{
+			name:      "invalid env",
+			env:       map[string]string{"APP_SERVICE_READ_TIMEOUT": "soon"},
+			wantField: "service.read_timeout",
+		},
+		{
+			name: "invalid flag",
+			args: []string{"-service.read-timeout", "soon"},
+		},
+		{
+			name: "unexpected argument",
+			args: []string{"extra"},
+		},
+		{
+			name:      "invalid value",
+			args:      []string{"-log.level", "loud"},
+			wantField: "log.level",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if tt.file != "" {
+				env = withEnv(env, configFileEnv, writeFile(t, tt.file))
+			}
+
+			_, err := Load("test", tt.args, lookup(env))
+			if err == nil {
+				t.Fatal("Load() returned no error")
+			}
+
+			var fieldErr *FieldError
+			if tt.wantField != "" && (!errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField) {
+				t.Errorf("Load() error = %v, want an error of field %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func withEnv(env map[string]string, key, value string) map[string]string {
+	c := map[string]string{key: value}
+	for k, v := range env {
+		c[k] = v
+	}
+
+	return c
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/internal/lifecycle"
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"github.com/theskch/prometheus-issue/internal/service"
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
+)
+
+// LogLevel returns the parsed log level. The configuration must be valid.
+func (c Config) LogLevel() logrus.Level {
+	level, _ := logrus.ParseLevel(c.Log.Level)
+
+	return level
+}
+
+// ServiceOptions returns the options of the service server. The
+// configuration must be valid.
+func (c Config) ServiceOptions() []service.Option {
+	opts := []service.Option{
+		service.WithAddress(c.Service.Address),
+		service.WithReadHeaderTimeout(c.Service.ReadHeaderTimeout),
+		service.WithReadTimeout(c.Service.ReadTimeout),
+		service.WithWriteTimeout(c.Service.WriteTimeout),
+		service.WithIdleTimeout(c.Service.IdleTimeout),
+		service.WithMaxHeaderBytes(c.Service.MaxHeaderBytes),
+		service.WithMaxBodyBytes(c.Service.MaxBodyBytes),
+		service.WithHandlerTimeout(c.Service.HandlerTimeout),
+		service.WithAccessLog(c.AccessLogOptions()),
+		service.WithRateLimit(c.RateLimitOptions()),
+		service.WithConcurrencyLimit(c.concurrencyLimitOptions()),
+		service.WithAPIExplorer(c.Service.APIExplorer),
+		service.WithTLS(c.Service.TLS.options()),
+	}
+
+	for route, timeout := range c.Service.RouteTimeouts {
+		opts = append(opts, service.WithRouteTimeout(route, timeout))
+	}
+
+	return opts
+}
+
+// MonitoringOptions returns the options of the monitoring server. The
+// configuration must be valid.
+func (c Config) MonitoringOptions() []monitoring.Option {
+	opts := []monitoring.Option{
+		monitoring.WithAddress(c.Monitoring.Address),
+		monitoring.WithReadHeaderTimeout(c.Monitoring.ReadHeaderTimeout),
+		monitoring.WithReadTimeout(c.Monitoring.ReadTimeout),
+		monitoring.WithWriteTimeout(c.Monitoring.WriteTimeout),
+		monitoring.WithIdleTimeout(c.Monitoring.IdleTimeout),
+		monitoring.WithMaxHeaderBytes(c.Monitoring.MaxHeaderBytes),
+		monitoring.WithTLS(c.Monitoring.TLS.options()),
+		monitoring.WithWebConfigFile(c.Monitoring.WebConfigFile),
+		monitoring.WithAuthExemptHealth(c.Monitoring.AuthExemptHealth),
+		monitoring.WithRegistryOptions(
+			monitoring.WithLatencyHistogram(c.latencyHistogram()),
+			monitoring.WithIDLabel(c.IDLabelOptions()),
+		),
+	}
+
+	if c.Monitoring.DefaultRegistry {
+		opts = append(opts, monitoring.WithDefaultRegistry())
+	}
+
+	return opts
+}
+
+// AccessLogOptions returns the options of the access log. The configuration
+// must be valid.
+func (c Config) AccessLogOptions() service.AccessLogOptions {
+	opts, _ := c.accessLogOptions()
+
+	return opts
+}
+
+func (c Config) IDLabelOptions() monitoring.CardinalityOptions {
+	return monitoring.CardinalityOptions{
+		AllowList: c.Monitoring.IDLabel.AllowList,
+		MaxValues: c.Monitoring.IDLabel.MaxValues,
+	}
+}
+
+func (c Config) Lifecycle() lifecycle.Config {
+	return lifecycle.Config{
+		PreStopDelay:              c.Shutdown.PreStopDelay,
+		DrainTimeout:              c.Shutdown.DrainTimeout,
+		FinalScrapeWindow:         c.Shutdown.FinalScrapeWindow,
+		MonitoringShutdownTimeout: c.Shutdown.MonitoringTimeout,
+	}
+}
+
+// RateLimitOptions returns the options of the rate limiter.
+func (c Config) RateLimitOptions() service.RateLimitOptions {
+	return service.RateLimitOptions{
+		Key:         service.RateLimitKey(c.Service.RateLimit.Key),
+		APIKeys:     c.Service.RateLimit.APIKeys,
+		ClientRate:  c.Service.RateLimit.ClientRate,
+		ClientBurst: c.Service.RateLimit.ClientBurst,
+		MaxClients:  c.Service.RateLimit.MaxClients,
+		GlobalRate:  c.Service.RateLimit.GlobalRate,
+		GlobalBurst: c.Service.RateLimit.GlobalBurst,
+	}
+}
+
+func (c Config) concurrencyLimitOptions() service.ConcurrencyLimitOptions {
+	return service.ConcurrencyLimitOptions{
+		Limit:            c.Service.ConcurrencyLimit.Limit,
+		Adaptive:         c.Service.ConcurrencyLimit.Adaptive,
+		MinLimit:         c.Service.ConcurrencyLimit.MinLimit,
+		MaxLimit:         c.Service.ConcurrencyLimit.MaxLimit,
+		LatencyThreshold: c.Service.ConcurrencyLimit.LatencyThreshold,
+		BackoffRatio:     c.Service.ConcurrencyLimit.BackoffRatio,
+	}
+}
+
+func (t TLS) options() tlsconfig.Options {
+	return tlsconfig.Options{
+		CertFile:       t.CertFile,
+		KeyFile:        t.KeyFile,
+		ClientCAFile:   t.ClientCAFile,
+		ReloadInterval: t.ReloadInterval,
+	}
+}
+
+func (c Config) accessLogOptions() (service.AccessLogOptions, error) {
+	opts := service.AccessLogOptions{
+		SuccessSampleRate: c.Service.AccessLog.SuccessSampleRate,
+	}
+
+	levels := []struct {
+		field string
+		value string
+		level *logrus.Level
+	}{
+		{"informational_level", c.Service.AccessLog.InformationalLevel, &opts.InformationalLevel},
+		{"success_level", c.Service.AccessLog.SuccessLevel, &opts.SuccessLevel},
+		{"redirection_level", c.Service.AccessLog.RedirectionLevel, &opts.RedirectionLevel},
+		{"client_error_level", c.Service.AccessLog.ClientErrorLevel, &opts.ClientErrorLevel},
+		{"server_error_level", c.Service.AccessLog.ServerErrorLevel, &opts.ServerErrorLevel},
+	}
+
+	for _, l := range levels {
+		level, err := logrus.ParseLevel(l.value)
+		if err != nil {
+			return opts, fmt.Errorf("%s: %w", l.field, err)
+		}
+
+		*l.level = level
+	}
+
+	return opts, opts.Validate()
+}
+
+func (c Config) latencyHistogram() monitoring.LatencyHistogram {
+	buckets := c.Monitoring.LatencyBuckets.Buckets
+	if len(buckets) == 0 {
+		buckets = monitoring.LatencyBucketPresets[c.Monitoring.LatencyBuckets.Preset]
+	}
+
+	return monitoring.LatencyHistogram{
+		Buckets:               buckets,
+		Native:                c.Monitoring.Native.Enabled,
+		NativeBucketFactor:    c.Monitoring.Native.BucketFactor,
+		NativeMaxBucketNumber: c.Monitoring.Native.MaxBucketNumber,
+	}
+}
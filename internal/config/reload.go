@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reloader re-reads the configuration and applies the settings that can
+// change while the servers are running: the log level, the access log, the
+// ID label limits and the rate limits. Changes to other settings are only
+// reported, they require a restart.
+type Reloader struct {
+	load    func() (Config, error)
+	observe func(success bool)
+	logger  *logrus.Entry
+
+	// started is the configuration the servers were started with, against
+	// which changes requiring a restart are detected.
+	started Config
+
+	m        sync.Mutex
+	appliers []func(Config)
+}
+
+// NewReloader returns a reloader of the started configuration. load reads the
+// new configuration and observe is told about the outcome of every reload.
+func NewReloader(started Config, load func() (Config, error), observe func(success bool)) *Reloader {
+	return &Reloader{
+		load:    load,
+		observe: observe,
+		logger:  logrus.NewEntry(logrus.StandardLogger()),
+		started: started,
+	}
+}
+
+// OnReload registers a function applying the reloaded configuration.
+func (r *Reloader) OnReload(apply func(Config)) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.appliers = append(r.appliers, apply)
+}
+
+func (r *Reloader) Reload() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		r.observe(false)
+		r.logger.WithError(err).Error("Rejected configuration reload")
+
+		return fmt.Errorf("reload configuration: %w", err)
+	}
+
+	if !reflect.DeepEqual(withoutReloadable(r.started), withoutReloadable(cfg)) {
+		r.logger.Warning("Configuration changes other than log level, access log, ID label and rate limits require a restart")
+	}
+
+	for _, apply := range r.appliers {
+		apply(cfg)
+	}
+
+	r.observe(true)
+	r.logger.Info("Reloaded configuration")
+
+	return nil
+}
+
+func withoutReloadable(c Config) Config {
+	c.Log.Level = ""
+	c.Service.AccessLog = AccessLog{}
+	c.Service.RateLimit = RateLimit{}
+	c.Monitoring.IDLabel = IDLabel{}
+
+	return c
+}
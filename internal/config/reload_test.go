@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"github.com/theskch/prometheus-issue/internal/service"
+)
+
+// warnings is a logrus hook counting the warnings logged.
+type warnings int
+
+func (w *warnings) Levels() []logrus.Level {
+	return []logrus.Level{logrus.WarnLevel}
+}
+
+func (w *warnings) Fire(*logrus.Entry) error {
+	*w++
+
+	return nil
+}
+
+func TestReloaderReload(t *testing.T) {
+	reloadable := Default()
+	reloadable.Log.Level = "debug"
+	reloadable.Service.AccessLog.SuccessSampleRate = 0.5
+	reloadable.Service.RateLimit.ClientRate = 10
+	reloadable.Monitoring.IDLabel.AllowList = []string{"1", "2"}
+
+	restart := Default()
+	restart.Service.Address = ":9090"
+
+	tests := []struct {
+		name        string
+		load        func() (Config, error)
+		wantErr     bool
+		wantApplied *Config
+		wantSuccess float64
+		wantWarning bool
+	}{
+		{
+			name:        "rejected",
+			load:        func() (Config, error) { return Config{}, errors.New("invalid") },
+			wantErr:     true,
+			wantSuccess: 0,
+		},
+		{
+			name:        "unchanged",
+			load:        func() (Config, error) { return Default(), nil },
+			wantApplied: ptr(Default()),
+			wantSuccess: 1,
+		},
+		{
+			name:        "reloadable settings",
+			load:        func() (Config, error) { return reloadable, nil },
+			wantApplied: &reloadable,
+			wantSuccess: 1,
+		},
+		{
+			name:        "restart required",
+			load:        func() (Config, error) { return restart, nil },
+			wantApplied: &restart,
+			wantSuccess: 1,
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := monitoring.NewRegistry()
+			r := NewReloader(Default(), tt.load, registry.ObserveConfigReload)
+
+			var warned warnings
+			log := logrus.New()
+			log.SetOutput(io.Discard)
+			log.AddHook(&warned)
+			r.logger = logrus.NewEntry(log)
+
+			var (
+				level     logrus.Level
+				accessLog *service.AccessLogOptions
+				rateLimit *service.RateLimitOptions
+				idLabel   *monitoring.CardinalityOptions
+			)
+			r.OnReload(func(c Config) { level = c.LogLevel() })
+			r.OnReload(func(c Config) { accessLog = ptr(c.AccessLogOptions()) })
+			r.OnReload(func(c Config) { rateLimit = ptr(c.RateLimitOptions()) })
+			r.OnReload(func(c Config) { idLabel = ptr(c.IDLabelOptions()) })
+
+			err := r.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if tt.wantApplied == nil {
+				if accessLog != nil || rateLimit != nil || idLabel != nil {
+					t.Error("rejected reload applied settings")
+				}
+			} else {
+				want := *tt.wantApplied
+				if level != want.LogLevel() {
+					t.Errorf("log level = %s, want %s", level, want.LogLevel())
+				}
+				if accessLog == nil || *accessLog != want.AccessLogOptions() {
+					t.Errorf("access log = %+v, want %+v", accessLog, want.AccessLogOptions())
+				}
+				if rateLimit == nil || rateLimit.ClientRate != want.RateLimitOptions().ClientRate {
+					t.Errorf("rate limit = %+v, want %+v", rateLimit, want.RateLimitOptions())
+				}
+				if idLabel == nil || !slices.Equal(idLabel.AllowList, want.IDLabelOptions().AllowList) {
+					t.Errorf("ID label = %+v, want %+v", idLabel, want.IDLabelOptions())
+				}
+			}
+
+			if got := reloadSuccess(t, registry); got != tt.wantSuccess {
+				t.Errorf("config_reload_success = %v, want %v", got, tt.wantSuccess)
+			}
+
+			if got := warned > 0; got != tt.wantWarning {
+				t.Errorf("restart required warning = %t, want %t", got, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func reloadSuccess(t *testing.T, registry *monitoring.Registry) float64 {
+	t.Helper()
+
+	families, err := registry.Gatherer().Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %s", err)
+	}
+
+	for _, f := range families {
+		if f.GetName() == "config_reload_success" {
+			return f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+
+	t.Fatal("config_reload_success was not gathered")
+
+	return 0
+}
@@ -0,0 +1,130 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDrainTimeout              = 5 * time.Second
+	defaultMonitoringShutdownTimeout = 5 * time.Second
+)
+
+// Config holds the durations of the shutdown phases. A zero PreStopDelay or
+// FinalScrapeWindow skips the phase.
+type Config struct {
+	// PreStopDelay is how long the service keeps serving after readiness is
+	// reported as failing, so that load balancers stop routing to it.
+	PreStopDelay time.Duration
+	// DrainTimeout bounds how long in-flight requests are waited for.
+	DrainTimeout time.Duration
+	// FinalScrapeWindow is how long metrics stay available after draining,
+	// so that the final values get scraped.
+	FinalScrapeWindow time.Duration
+	// MonitoringShutdownTimeout bounds the shutdown of the monitoring server.
+	MonitoringShutdownTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DrainTimeout:              defaultDrainTimeout,
+		MonitoringShutdownTimeout: defaultMonitoringShutdownTimeout,
+	}
+}
+
+// Service is the server whose requests are drained.
+type Service interface {
+	Shutdown(ctx context.Context) error
+	InFlight() int64
+}
+
+// Monitoring is the server exposing readiness and metrics.
+type Monitoring interface {
+	BeginShutdown()
+	Shutdown(ctx context.Context) error
+}
+
+// Coordinator shuts the servers down in phases: it marks the process as not
+// ready, waits for the pre-stop delay, drains the service server, keeps the
+// metrics up for a final scrape and then stops the monitoring server.
+// Cancelling the context of the shutdown skips the remaining delays and
+// stops waiting for the servers.
+type Coordinator struct {
+	cfg        Config
+	service    Service
+	monitoring Monitoring
+	logger     *logrus.Entry
+}
+
+func NewCoordinator(cfg Config, service Service, monitoring Monitoring) *Coordinator {
+	return &Coordinator{
+		cfg:        cfg,
+		service:    service,
+		monitoring: monitoring,
+		logger:     logrus.NewEntry(logrus.StandardLogger()),
+	}
+}
+
+func (c *Coordinator) Shutdown(ctx context.Context) error {
+	c.logger.Info("Marking service as not ready")
+	c.monitoring.BeginShutdown()
+
+	if c.cfg.PreStopDelay > 0 {
+		c.logger.WithField("delay", c.cfg.PreStopDelay).Info("Waiting before stopping service server")
+		c.wait(ctx, c.cfg.PreStopDelay)
+	}
+
+	c.logger.Info("Stopping service server")
+	drainErr := c.drain(ctx)
+
+	if c.cfg.FinalScrapeWindow > 0 {
+		c.logger.WithField("window", c.cfg.FinalScrapeWindow).Info("Waiting for final metrics scrape")
+		c.wait(ctx, c.cfg.FinalScrapeWindow)
+	}
+
+	c.logger.Info("Stopping monitoring server")
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.MonitoringShutdownTimeout)
+	defer cancel()
+
+	if err := c.monitoring.Shutdown(ctx); err != nil {
+		return errors.Join(drainErr, fmt.Errorf("stop monitoring server: %w", err))
+	}
+
+	return drainErr
+}
+
+// wait waits for d, or until ctx is done.
+func (c *Coordinator) wait(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		c.logger.Warning("Shutdown delay interrupted")
+	}
+}
+
+func (c *Coordinator) drain(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.DrainTimeout)
+	defer cancel()
+
+	err := c.service.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		inFlight := c.service.InFlight()
+		c.logger.WithField("in_flight", inFlight).Warning("Drain interrupted with requests in flight")
+
+		return fmt.Errorf("drain service server: %d requests in flight: %w", inFlight, err)
+	}
+
+	return fmt.Errorf("drain service server: %w", err)
+}
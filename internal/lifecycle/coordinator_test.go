@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	shutdownErr error
+}
+
+func (s *fakeService) Shutdown(ctx context.Context) error {
+	if s.shutdownErr != nil {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func (s *fakeService) InFlight() int64 {
+	return 0
+}
+
+type fakeMonitoring struct {
+	shutdown bool
+}
+
+func (m *fakeMonitoring) BeginShutdown() {}
+
+func (m *fakeMonitoring) Shutdown(context.Context) error {
+	m.shutdown = true
+	return nil
+}
+
+func TestCoordinatorShutdownInterrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		service *fakeService
+		wantErr error
+	}{
+		{
+			name:    "delays",
+			service: &fakeService{},
+		},
+		{
+			name:    "drain",
+			service: &fakeService{shutdownErr: errors.New("stuck")},
+			wantErr: context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			monitoring := &fakeMonitoring{}
+			c := NewCoordinator(Config{
+				PreStopDelay:              time.Hour,
+				DrainTimeout:              time.Hour,
+				FinalScrapeWindow:         time.Hour,
+				MonitoringShutdownTimeout: time.Hour,
+			}, tt.service, monitoring)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+
+			done := make(chan error)
+			go func() { done <- c.Shutdown(ctx) }()
+
+			select {
+			case err := <-done:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Shutdown() = %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Shutdown did not return once the context was cancelled")
+			}
+
+			if !monitoring.shutdown {
+				t.Error("monitoring server was not shut down")
+			}
+		})
+	}
+}
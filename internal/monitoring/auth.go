@@ -0,0 +1,200 @@
+package monitoring
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	authFailureMissing = "missing_credentials"
+	authFailureBasic   = "invalid_basic_auth"
+	authFailureBearer  = "invalid_bearer_token"
+
+	// maxCachedCredentials bounds the cache of verified basic auth
+	// credentials, which saves a bcrypt comparison on every scrape.
+	maxCachedCredentials = 100
+)
+
+// WebConfig holds the credentials accepted by the monitoring server. It is
+// read from a file in the format of the Prometheus web configuration file,
+// with bearer_tokens in addition to basic_auth_users.
+//
+//	basic_auth_users:
+//	  prometheus: $2y$10$...
+//	bearer_tokens:
+//	  - secret
+type WebConfig struct {
+	// BasicAuthUsers maps user names to bcrypt-hashed passwords.
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+	BearerTokens   []string          `yaml:"bearer_tokens"`
+}
+
+// loadWebConfig reads and validates the web configuration file at path.
+func loadWebConfig(path string) (WebConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return WebConfig{}, err
+	}
+
+	var c WebConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil {
+		return WebConfig{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return WebConfig{}, fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func (c WebConfig) Validate() error {
+	var errs []error
+
+	if len(c.BasicAuthUsers) == 0 && len(c.BearerTokens) == 0 {
+		errs = append(errs, errors.New("no basic auth users or bearer tokens"))
+	}
+
+	for user, hash := range c.BasicAuthUsers {
+		if user == "" || strings.Contains(user, ":") {
+			errs = append(errs, fmt.Errorf("invalid basic auth user name %q", user))
+		}
+
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			errs = append(errs, fmt.Errorf("password of basic auth user %q: %w", user, err))
+		}
+	}
+
+	for i, token := range c.BearerTokens {
+		if token == "" {
+			errs = append(errs, fmt.Errorf("bearer token %d is empty", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// authenticator checks the credentials of requests against a WebConfig.
+type authenticator struct {
+	config   WebConfig
+	failures *prometheus.CounterVec
+
+	// dummyHash is compared against for unknown users, so that they take
+	// as long to reject as known ones.
+	dummyHash []byte
+
+	m        sync.Mutex
+	verified map[[sha256.Size]byte]struct{}
+}
+
+func newAuthenticator(config WebConfig, failures *prometheus.CounterVec) (*authenticator, error) {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authenticator{
+		config:    config,
+		failures:  failures,
+		dummyHash: dummyHash,
+		verified:  make(map[[sha256.Size]byte]struct{}),
+	}, nil
+}
+
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := a.authenticate(r); reason != "" {
+			a.failures.WithLabelValues(reason).Inc()
+
+			if len(a.config.BasicAuthUsers) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="monitoring"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the reason the request is rejected, or an empty
+// string if its credentials are valid.
+func (a *authenticator) authenticate(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if len(a.config.BearerTokens) == 0 || !a.validToken(token) {
+			return authFailureBearer
+		}
+
+		return ""
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return authFailureMissing
+	}
+
+	if len(a.config.BasicAuthUsers) == 0 || !a.validPassword(user, password) {
+		return authFailureBasic
+	}
+
+	return ""
+}
+
+func (a *authenticator) validToken(token string) bool {
+	valid := false
+	for _, t := range a.config.BearerTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+
+	return valid
+}
+
+func (a *authenticator) validPassword(user, password string) bool {
+	hash, known := a.config.BasicAuthUsers[user]
+
+	key := sha256.Sum256([]byte(user + ":" + password + ":" + hash))
+
+	a.m.Lock()
+	_, cached := a.verified[key]
+	a.m.Unlock()
+
+	if cached {
+		return true
+	}
+
+	if !known {
+		_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return false
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	a.m.Lock()
+	if len(a.verified) >= maxCachedCredentials {
+		clear(a.verified)
+	}
+	a.verified[key] = struct{}{}
+	a.m.Unlock()
+
+	return true
+}
@@ -0,0 +1,221 @@
+package monitoring
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// OtherLabelValue is the label value unseen or excess values are folded into.
+	OtherLabelValue = "other"
+
+	defaultMaxIDLabelValues = 100
+)
+
+// CardinalityOptions configures which values a CardinalityLimiter lets through.
+// When AllowList is set only the listed values are kept, otherwise the
+// MaxValues most frequent values are tracked. A zero MaxValues without an
+// allow-list disables the limiter.
+type CardinalityOptions struct {
+	AllowList []string
+	MaxValues int
+}
+
+// CardinalityLimiter bounds the number of distinct values a label can take by
+// folding everything it does not track into OtherLabelValue.
+//
+// Without an allow-list, the first MaxValues values are tracked, and the hits
+// of as many untracked values are counted. An untracked value replaces the
+// least hit tracked value once it is hit more often, so that the tracked
+// values converge to the most frequent ones.
+type CardinalityLimiter struct {
+	label  string
+	folded prometheus.Counter
+	// evicted is called with the values no longer tracked, so that their
+	// series can be deleted.
+	evicted func(value string)
+
+	m          sync.RWMutex
+	allowed    map[string]struct{}
+	maxValues  int
+	tracked    map[string]*atomic.Int64
+	candidates map[string]int64
+}
+
+// NewCardinalityLimiter returns a limiter for label whose folded values are
+// counted in the registry.
+func (r *Registry) NewCardinalityLimiter(label string, opts CardinalityOptions) *CardinalityLimiter {
+	l := &CardinalityLimiter{
+		label:  label,
+		folded: r.labelValuesFolded.WithLabelValues(label),
+	}
+	l.Configure(opts)
+
+	return l
+}
+
+// Configure replaces the limiter options. Values tracked so far are
+// forgotten, unless the options are unchanged. Registry.ConfigureIDLabel
+// deletes the series of the values no longer let through.
+func (l *CardinalityLimiter) Configure(opts CardinalityOptions) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.tracked != nil && l.equal(opts) {
+		return
+	}
+
+	var allowed map[string]struct{}
+	if len(opts.AllowList) > 0 {
+		allowed = make(map[string]struct{}, len(opts.AllowList))
+		for _, v := range opts.AllowList {
+			allowed[v] = struct{}{}
+		}
+	}
+
+	l.allowed = allowed
+	l.maxValues = opts.MaxValues
+	l.tracked = make(map[string]*atomic.Int64)
+	l.candidates = make(map[string]int64)
+}
+
+// Value returns the label value to use for v.
+func (l *CardinalityLimiter) Value(v string) string {
+	l.m.RLock()
+	ok := l.admitted(v)
+	l.m.RUnlock()
+
+	var evicted string
+	if !ok {
+		l.m.Lock()
+		ok, evicted = l.track(v)
+		l.m.Unlock()
+	}
+
+	if evicted != "" && l.evicted != nil {
+		l.evicted(evicted)
+	}
+
+	if !ok {
+		l.folded.Inc()
+		return OtherLabelValue
+	}
+
+	return v
+}
+
+// current returns v if it is still let through, and OtherLabelValue
+// otherwise. Unlike Value, it counts no hit.
+func (l *CardinalityLimiter) current(v string) string {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	if v == OtherLabelValue || l.allowed == nil && l.maxValues <= 0 {
+		return v
+	}
+
+	if l.allowed != nil {
+		if _, ok := l.allowed[v]; ok {
+			return v
+		}
+	} else if _, ok := l.tracked[v]; ok {
+		return v
+	}
+
+	return OtherLabelValue
+}
+
+func (l *CardinalityLimiter) equal(opts CardinalityOptions) bool {
+	if len(opts.AllowList) == 0 {
+		return l.allowed == nil && l.maxValues == opts.MaxValues
+	}
+
+	if l.allowed == nil || l.maxValues != opts.MaxValues {
+		return false
+	}
+
+	for _, v := range opts.AllowList {
+		if _, ok := l.allowed[v]; !ok {
+			return false
+		}
+	}
+
+	return len(l.allowed) == len(opts.AllowList)
+}
+
+// admitted reports whether v is let through, counting a hit of tracked
+// values. It only needs the read lock.
+func (l *CardinalityLimiter) admitted(v string) bool {
+	if l.allowed != nil {
+		_, ok := l.allowed[v]
+		return ok
+	}
+
+	if l.maxValues <= 0 {
+		return true
+	}
+
+	hits, ok := l.tracked[v]
+	if ok {
+		hits.Add(1)
+	}
+
+	return ok
+}
+
+// track counts a hit of v, and returns whether v is let through along with
+// the tracked value it replaced, if any.
+func (l *CardinalityLimiter) track(v string) (bool, string) {
+	if l.admitted(v) {
+		return true, ""
+	}
+
+	if l.allowed != nil {
+		return false, ""
+	}
+
+	if len(l.tracked) < l.maxValues {
+		l.tracked[v] = new(atomic.Int64)
+		l.tracked[v].Store(1)
+		return true, ""
+	}
+
+	hits, ok := l.candidates[v]
+	if !ok && len(l.candidates) >= l.maxValues {
+		delete(l.candidates, leastHit(l.candidates))
+	}
+	hits++
+
+	least, leastHits := "", int64(0)
+	for t, n := range l.tracked {
+		if n := n.Load(); least == "" || n < leastHits {
+			least, leastHits = t, n
+		}
+	}
+
+	if hits <= leastHits {
+		l.candidates[v] = hits
+		return false, ""
+	}
+
+	delete(l.candidates, v)
+	delete(l.tracked, least)
+	l.candidates[least] = leastHits
+	l.tracked[v] = new(atomic.Int64)
+	l.tracked[v].Store(hits)
+
+	return true, least
+}
+
+func leastHit(values map[string]int64) string {
+	least, leastHits := "", int64(0)
+	for v, n := range values {
+		if least == "" || n < leastHits {
+			least, leastHits = v, n
+		}
+	}
+
+	return least
+}
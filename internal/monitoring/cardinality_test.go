@@ -0,0 +1,143 @@
+package monitoring
+
+import (
+	"slices"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCardinalityLimiterValue(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        CardinalityOptions
+		values      []string
+		want        []string
+		wantFolded  float64
+		wantEvicted []string
+	}{
+		{
+			name:   "disabled",
+			opts:   CardinalityOptions{},
+			values: []string{"1", "2", "3"},
+			want:   []string{"1", "2", "3"},
+		},
+		{
+			name:       "allow-list",
+			opts:       CardinalityOptions{AllowList: []string{"1", "2"}, MaxValues: 1},
+			values:     []string{"1", "3", "2", "3"},
+			want:       []string{"1", OtherLabelValue, "2", OtherLabelValue},
+			wantFolded: 2,
+		},
+		{
+			name:       "first values tracked",
+			opts:       CardinalityOptions{MaxValues: 2},
+			values:     []string{"1", "2", "3", "1", "2"},
+			want:       []string{"1", "2", OtherLabelValue, "1", "2"},
+			wantFolded: 1,
+		},
+		{
+			name:        "more frequent value replaces least hit",
+			opts:        CardinalityOptions{MaxValues: 1},
+			values:      []string{"1", "2", "2", "1", "2"},
+			want:        []string{"1", OtherLabelValue, "2", OtherLabelValue, "2"},
+			wantFolded:  2,
+			wantEvicted: []string{"1"},
+		},
+		{
+			name:        "replaced value comes back",
+			opts:        CardinalityOptions{MaxValues: 1},
+			values:      []string{"1", "2", "2", "1", "1", "2"},
+			want:        []string{"1", OtherLabelValue, "2", OtherLabelValue, "1", OtherLabelValue},
+			wantFolded:  3,
+			wantEvicted: []string{"1", "2"},
+		},
+		{
+			name:       "candidates are bounded",
+			opts:       CardinalityOptions{MaxValues: 1},
+			values:     []string{"1", "1", "2", "3", "2", "4"},
+			want:       []string{"1", "1", OtherLabelValue, OtherLabelValue, OtherLabelValue, OtherLabelValue},
+			wantFolded: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRegistry().NewCardinalityLimiter("ID", tt.opts)
+
+			var evicted []string
+			l.evicted = func(v string) { evicted = append(evicted, v) }
+
+			var got []string
+			for _, v := range tt.values {
+				got = append(got, l.Value(v))
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(evicted, tt.wantEvicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if folded := counterValue(t, l); folded != tt.wantFolded {
+				t.Errorf("folded = %v, want %v", folded, tt.wantFolded)
+			}
+			if len(l.candidates) > tt.opts.MaxValues {
+				t.Errorf("%d candidates, want at most %d", len(l.candidates), tt.opts.MaxValues)
+			}
+		})
+	}
+}
+
+func TestCardinalityLimiterConfigure(t *testing.T) {
+	tests := []struct {
+		name string
+		opts CardinalityOptions
+		want string
+	}{
+		{
+			name: "unchanged options keep tracked values",
+			opts: CardinalityOptions{MaxValues: 1},
+			want: OtherLabelValue,
+		},
+		{
+			name: "changed max values forgets tracked values",
+			opts: CardinalityOptions{MaxValues: 2},
+			want: "2",
+		},
+		{
+			name: "allow-list replaces tracking",
+			opts: CardinalityOptions{AllowList: []string{"2"}, MaxValues: 1},
+			want: "2",
+		},
+		{
+			name: "disabling lets everything through",
+			opts: CardinalityOptions{},
+			want: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRegistry().NewCardinalityLimiter("ID", CardinalityOptions{MaxValues: 1})
+			l.Value("1")
+
+			l.Configure(tt.opts)
+
+			if got := l.Value("2"); got != tt.want {
+				t.Errorf("Value(2) = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func counterValue(t *testing.T, l *CardinalityLimiter) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := l.folded.Write(&m); err != nil {
+		t.Fatalf("write folded counter: %s", err)
+	}
+
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,198 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxGatherErrors          = 100
+	maxGatherErrorLabelSets  = 10
+	unknownMetricFamilyLabel = "unknown"
+)
+
+var (
+	gatherErrorFamilyRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`collected (?:metric|histogram or summary|histogram) (?:named )?"?([a-zA-Z_:][a-zA-Z0-9_:]*)`),
+		regexp.MustCompile(`metric family "?([a-zA-Z_:][a-zA-Z0-9_:]*)`),
+		regexp.MustCompile(`fqName: "([^"]+)"`),
+	}
+	gatherErrorLabelRegexp  = regexp.MustCompile(`name:"((?:[^"\\]|\\.)*)"\s+value:"((?:[^"\\]|\\.)*)"`)
+	gatherErrorMetricRegexp = regexp.MustCompile(`\{.*\}`)
+	gatherErrorQuotedRegexp = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+)
+
+// GatherError describes a distinct error returned while gathering metrics.
+type GatherError struct {
+	Family    string              `json:"family"`
+	LabelSets []map[string]string `json:"label_sets"`
+	Error     string              `json:"error"`
+	Count     int                 `json:"count"`
+	FirstSeen time.Time           `json:"first_seen"`
+	LastSeen  time.Time           `json:"last_seen"`
+}
+
+// diagnosingGatherer records, logs and counts every error returned by the
+// wrapped gatherer.
+type diagnosingGatherer struct {
+	gatherer    prometheus.Gatherer
+	logger      *logrus.Entry
+	errorsTotal prometheus.Counter
+
+	m      sync.Mutex
+	errors map[string]*GatherError
+}
+
+func newDiagnosingGatherer(gatherer prometheus.Gatherer, errorsTotal prometheus.Counter) *diagnosingGatherer {
+	return &diagnosingGatherer{
+		gatherer:    gatherer,
+		logger:      logrus.NewEntry(logrus.StandardLogger()),
+		errorsTotal: errorsTotal,
+		errors:      make(map[string]*GatherError),
+	}
+}
+
+func (g *diagnosingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	if err == nil {
+		return mfs, nil
+	}
+
+	errs := prometheus.MultiError{err}
+
+	var multiErr prometheus.MultiError
+	if errors.As(err, &multiErr) {
+		errs = multiErr
+	}
+
+	now := time.Now()
+	for _, err := range errs {
+		g.record(err, now)
+	}
+
+	return mfs, err
+}
+
+func (g *diagnosingGatherer) record(err error, now time.Time) {
+	family, labels := parseGatherError(err)
+
+	g.errorsTotal.Inc()
+	g.logger.WithError(err).WithFields(logrus.Fields{
+		"family": family,
+		"labels": labels,
+	}).Error("Failed to gather metrics")
+
+	key := family + "\x00" + gatherErrorQuotedRegexp.ReplaceAllString(
+		gatherErrorMetricRegexp.ReplaceAllString(err.Error(), ""), "")
+
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	e, ok := g.errors[key]
+	if !ok {
+		if len(g.errors) >= maxGatherErrors {
+			return
+		}
+
+		e = &GatherError{
+			Family:    family,
+			LabelSets: []map[string]string{},
+			Error:     err.Error(),
+			FirstSeen: now,
+		}
+		g.errors[key] = e
+	}
+
+	e.Count++
+	e.LastSeen = now
+
+	if len(labels) > 0 && len(e.LabelSets) < maxGatherErrorLabelSets && !containsLabelSet(e.LabelSets, labels) {
+		e.LabelSets = append(e.LabelSets, labels)
+	}
+}
+
+// Errors returns the recorded gather errors ordered by the time they were first seen.
+func (g *diagnosingGatherer) Errors() []GatherError {
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	errs := make([]GatherError, 0, len(g.errors))
+	for _, e := range g.errors {
+		c := *e
+		c.LabelSets = append([]map[string]string(nil), e.LabelSets...)
+		errs = append(errs, c)
+	}
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].FirstSeen.Before(errs[j].FirstSeen)
+	})
+
+	return errs
+}
+
+func (g *diagnosingGatherer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(struct {
+		Errors []GatherError `json:"errors"`
+	}{
+		Errors: g.Errors(),
+	})
+}
+
+// parseGatherError extracts the metric family and the label set of the
+// offending metric from the error messages produced by client_golang, which
+// carry no structured data. The tests pin the messages of the vendored
+// version.
+func parseGatherError(err error) (string, map[string]string) {
+	msg := err.Error()
+
+	family := unknownMetricFamilyLabel
+	for _, re := range gatherErrorFamilyRegexps {
+		if m := re.FindStringSubmatch(msg); m != nil {
+			family = m[1]
+			break
+		}
+	}
+
+	var labels map[string]string
+	for _, m := range gatherErrorLabelRegexp.FindAllStringSubmatch(msg, -1) {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[m[1]] = m[2]
+	}
+
+	return family, labels
+}
+
+func containsLabelSet(sets []map[string]string, labels map[string]string) bool {
+	for _, set := range sets {
+		if len(set) != len(labels) {
+			continue
+		}
+
+		equal := true
+		for k, v := range labels {
+			if set[k] != v {
+				equal = false
+				break
+			}
+		}
+
+		if equal {
+			return true
+		}
+	}
+
+	return false
+}
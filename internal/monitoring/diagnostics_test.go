@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// constCollector collects fixed metrics without describing them, so that the
+// registry only finds out about inconsistencies while gathering.
+type constCollector []prometheus.Metric
+
+func (c constCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
+
+// TestParseGatherError parses the errors client_golang actually returns, so
+// that a change of their wording fails here rather than silently turning
+// every family into unknown.
+func TestParseGatherError(t *testing.T) {
+	counter := prometheus.NewDesc("requests_total", "Number of requests", []string{"id"}, nil)
+
+	tests := []struct {
+		name       string
+		metrics    constCollector
+		wantFamily string
+		wantLabels map[string]string
+	}{
+		{
+			name: "duplicate label values",
+			metrics: constCollector{
+				prometheus.MustNewConstMetric(counter, prometheus.CounterValue, 1, "7"),
+				prometheus.MustNewConstMetric(counter, prometheus.CounterValue, 1, "7"),
+			},
+			wantFamily: "requests_total",
+			wantLabels: map[string]string{"id": "7"},
+		},
+		{
+			name: "invalid metric",
+			metrics: constCollector{
+				prometheus.NewInvalidMetric(prometheus.NewDesc("broken_total", "Broken", nil, nil), errors.New("boom")),
+			},
+			wantFamily: "broken_total",
+		},
+		{
+			name: "inconsistent type",
+			metrics: constCollector{
+				prometheus.MustNewConstMetric(prometheus.NewDesc("mixed", "Mixed", nil, nil), prometheus.GaugeValue, 1),
+				prometheus.MustNewConstMetric(prometheus.NewDesc("mixed", "Mixed", []string{"id"}, nil), prometheus.CounterValue, 1, "3"),
+			},
+			wantFamily: "mixed",
+			wantLabels: map[string]string{"id": "3"},
+		},
+		{
+			name: "inconsistent help",
+			metrics: constCollector{
+				prometheus.MustNewConstMetric(prometheus.NewDesc("helped", "One", nil, nil), prometheus.GaugeValue, 1),
+				prometheus.MustNewConstMetric(prometheus.NewDesc("helped", "Two", []string{"id"}, nil), prometheus.GaugeValue, 1, "4"),
+			},
+			wantFamily: "helped",
+			wantLabels: map[string]string{"id": "4"},
+		},
+		{
+			name: "histogram suffix collision",
+			metrics: constCollector{
+				prometheus.MustNewConstMetric(prometheus.NewDesc("latency_bucket", "Latency", nil, nil), prometheus.GaugeValue, 1),
+				prometheus.MustNewConstHistogram(prometheus.NewDesc("latency", "Latency", nil, nil), 1, 1, nil),
+			},
+			wantFamily: "latency",
+		},
+		{
+			name: "summary suffix collision",
+			metrics: constCollector{
+				prometheus.MustNewConstSummary(prometheus.NewDesc("duration", "Duration", nil, nil), 1, 1, nil),
+				prometheus.MustNewConstMetric(prometheus.NewDesc("duration_count", "Duration", nil, nil), prometheus.GaugeValue, 1),
+			},
+			wantFamily: "duration_count",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(tt.metrics)
+
+			_, err := reg.Gather()
+			if err == nil {
+				t.Fatal("Gather returned no error")
+			}
+
+			family, labels := parseGatherError(err)
+			if family != tt.wantFamily {
+				t.Errorf("family = %q, want %q, parsing %q", family, tt.wantFamily, err)
+			}
+			if !maps.Equal(labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v, parsing %q", labels, tt.wantLabels, err)
+			}
+		})
+	}
+}
+
+func TestParseGatherErrorUnknown(t *testing.T) {
+	family, labels := parseGatherError(errors.New("something went wrong"))
+	if family != unknownMetricFamilyLabel || labels != nil {
+		t.Errorf("parseGatherError = %q, %v, want %q, nil", family, labels, unknownMetricFamilyLabel)
+	}
+}
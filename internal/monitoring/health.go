@@ -0,0 +1,136 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	checkStatusOK      = "ok"
+	checkStatusFailing = "failing"
+)
+
+var errShuttingDown = errors.New("shutting down")
+
+// Check reports an error when the checked component is not healthy.
+type Check func() error
+
+// ServingCheck returns a check failing while s is not serving.
+func ServingCheck(s interface{ Serving() bool }) Check {
+	return func() error {
+		if !s.Serving() {
+			return errors.New("not serving")
+		}
+
+		return nil
+	}
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+// health aggregates the liveness and readiness checks of the server.
+type health struct {
+	m         sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+
+	shuttingDown int32
+}
+
+func newHealth() *health {
+	h := &health{}
+	h.readiness = append(h.readiness, namedCheck{
+		name: "shutdown",
+		check: func() error {
+			if atomic.LoadInt32(&h.shuttingDown) == 1 {
+				return errShuttingDown
+			}
+
+			return nil
+		},
+	})
+
+	return h
+}
+
+func (h *health) addLivenessCheck(name string, check Check) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.liveness = append(h.liveness, namedCheck{name: name, check: check})
+}
+
+func (h *health) addReadinessCheck(name string, check Check) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.readiness = append(h.readiness, namedCheck{name: name, check: check})
+}
+
+func (h *health) beginShutdown() {
+	atomic.StoreInt32(&h.shuttingDown, 1)
+}
+
+func (h *health) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	h.m.RLock()
+	checks := h.liveness
+	h.m.RUnlock()
+
+	renderChecks(w, checks)
+}
+
+func (h *health) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	h.m.RLock()
+	checks := h.readiness
+	h.m.RUnlock()
+
+	renderChecks(w, checks)
+}
+
+func renderChecks(w http.ResponseWriter, checks []namedCheck) {
+	resp := healthResponse{
+		Status: checkStatusOK,
+		Checks: make([]checkResult, 0, len(checks)),
+	}
+
+	for _, c := range checks {
+		result := checkResult{
+			Name:   c.name,
+			Status: checkStatusOK,
+		}
+
+		if err := c.check(); err != nil {
+			result.Status = checkStatusFailing
+			result.Error = err.Error()
+			resp.Status = checkStatusFailing
+		}
+
+		resp.Checks = append(resp.Checks, result)
+	}
+
+	statusCode := http.StatusOK
+	if resp.Status != checkStatusOK {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
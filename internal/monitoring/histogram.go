@@ -0,0 +1,79 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sharedprometheus "github.com/sliide/shared-go-libs/metric/prometheus"
+)
+
+const (
+	defaultNativeBucketFactor     = 1.1
+	defaultNativeMaxBucketNumber  = 160
+	defaultNativeMinResetDuration = time.Hour
+)
+
+// LatencyBucketPresets are the named bucket layouts selectable for the
+// request latency histogram.
+var LatencyBucketPresets = map[string][]float64{
+	"default": prometheus.DefBuckets,
+	"db":      sharedprometheus.DBLatencyBuckets,
+	"api":     sharedprometheus.APIRequestLatencyBuckets,
+}
+
+// LatencyHistogram configures the api_request_duration_seconds histogram.
+//
+// With Native set the histogram is also exposed as a Prometheus native
+// histogram, which scrapers receive when they negotiate the protobuf format.
+// Classic buckets are only kept alongside it when Buckets is set.
+type LatencyHistogram struct {
+	Buckets               []float64
+	Native                bool
+	NativeBucketFactor    float64
+	NativeMaxBucketNumber uint32
+}
+
+func (h LatencyHistogram) Validate() error {
+	if !sort.Float64sAreSorted(h.Buckets) {
+		return errors.New("buckets must be sorted in increasing order")
+	}
+
+	for i := 1; i < len(h.Buckets); i++ {
+		if h.Buckets[i] == h.Buckets[i-1] {
+			return fmt.Errorf("bucket %v is duplicated", h.Buckets[i])
+		}
+	}
+
+	if h.Native && h.NativeBucketFactor != 0 && h.NativeBucketFactor <= 1 {
+		return fmt.Errorf("native bucket factor must be greater than 1, got %v", h.NativeBucketFactor)
+	}
+
+	return nil
+}
+
+func (h LatencyHistogram) opts(name, help string) prometheus.HistogramOpts {
+	opts := prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: h.Buckets,
+	}
+
+	if h.Native {
+		opts.NativeHistogramBucketFactor = h.NativeBucketFactor
+		if opts.NativeHistogramBucketFactor == 0 {
+			opts.NativeHistogramBucketFactor = defaultNativeBucketFactor
+		}
+
+		opts.NativeHistogramMaxBucketNumber = h.NativeMaxBucketNumber
+		if opts.NativeHistogramMaxBucketNumber == 0 {
+			opts.NativeHistogramMaxBucketNumber = defaultNativeMaxBucketNumber
+		}
+
+		opts.NativeHistogramMinResetDuration = defaultNativeMinResetDuration
+	}
+
+	return opts
+}
@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sharedprometheus "github.com/sliide/shared-go-libs/metric/prometheus"
+)
+
+const (
+	kb, mb = 1e3, 1e6
+)
+
+// sizeBucketsInBytes mirrors the size buckets used by the shared-go-libs HTTP metrics.
+var sizeBucketsInBytes = []float64{100, 200, 500, kb, 2 * kb, 5 * kb, 10 * kb, 20 * kb, 50 * kb, 100 * kb, 500 * kb, mb, 2 * mb, 5 * mb, 10 * mb}
+
+// httpMetrics holds the HTTP handler metrics. Names, labels and buckets match
+// the ones of shared-go-libs so that dashboards work across services.
+type httpMetrics struct {
+	requestsInFlight  *prometheus.GaugeVec
+	requestsTotal     *prometheus.CounterVec
+	requestSizeBytes  *prometheus.HistogramVec
+	responseSizeBytes *prometheus.HistogramVec
+	durationSeconds   *prometheus.HistogramVec
+}
+
+func newHTTPMetrics(registerer prometheus.Registerer) httpMetrics {
+	return httpMetrics{
+		requestsInFlight: register(registerer, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "The current number of HTTP requests is being served.",
+			},
+			[]string{"method", "handler"})),
+		requestsTotal: register(registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_requests_total",
+				Help: "Total number of HTTP requests made and responded.",
+			},
+			[]string{"method", "handler", "code"})),
+		requestSizeBytes: register(registerer, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_requests_size_bytes",
+				Help:    "The HTTP request sizes in bytes.",
+				Buckets: sizeBucketsInBytes,
+			},
+			[]string{"method", "handler"})),
+		responseSizeBytes: register(registerer, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_responses_size_bytes",
+				Help:    "The HTTP response sizes in bytes.",
+				Buckets: sizeBucketsInBytes,
+			},
+			[]string{"method", "handler"})),
+		durationSeconds: register(registerer, prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_requests_duration_seconds",
+				Help:    "The HTTP request latencies in seconds.",
+				Buckets: sharedprometheus.APIRequestLatencyBuckets,
+			},
+			[]string{"method", "handler"})),
+	}
+}
+
+// WithHandler returns the HTTP metrics of a single handler. Together with it
+// the registry implements the shared-go-libs HTTPMetricer.
+func (r *Registry) WithHandler(method, handler string) sharedprometheus.HTTPHandlerMetricer {
+	return httpHandlerMetric{
+		metrics: r.http,
+		labels: prometheus.Labels{
+			"method":  strings.ToLower(method),
+			"handler": strings.ToLower(handler),
+		},
+	}
+}
+
+// ObservePanic counts a panic recovered from while serving route.
+func (r *Registry) ObservePanic(route string) {
+	r.panicsTotal.WithLabelValues(route).Inc()
+}
+
+// ObserveRateLimited counts a request to route rejected by the rate limiter.
+func (r *Registry) ObserveRateLimited(route string) {
+	r.rateLimitedTotal.WithLabelValues(route).Inc()
+}
+
+// SetConcurrencyLimit records the current limit of requests served at once.
+func (r *Registry) SetConcurrencyLimit(limit int) {
+	r.concurrencyLimit.Set(float64(limit))
+}
+
+// ObserveShed counts a request to route shed by the concurrency limiter.
+func (r *Registry) ObserveShed(route string) {
+	r.shedTotal.WithLabelValues(route).Inc()
+}
+
+type httpHandlerMetric struct {
+	metrics httpMetrics
+	labels  prometheus.Labels
+}
+
+func (m httpHandlerMetric) IncInFlight() {
+	m.metrics.requestsInFlight.With(m.labels).Inc()
+}
+
+func (m httpHandlerMetric) DecInFlight() {
+	m.metrics.requestsInFlight.With(m.labels).Dec()
+}
+
+func (m httpHandlerMetric) Inc(statusCode int) {
+	m.metrics.requestsTotal.With(prometheus.Labels{
+		"method":  m.labels["method"],
+		"handler": m.labels["handler"],
+		"code":    strconv.Itoa(statusCode),
+	}).Inc()
+}
+
+func (m httpHandlerMetric) ObserveRequestSize(sizeInBytes int64) {
+	m.metrics.requestSizeBytes.With(m.labels).Observe(float64(sizeInBytes))
+}
+
+func (m httpHandlerMetric) ObserveResponseSize(sizeInBytes int64) {
+	m.metrics.responseSizeBytes.With(m.labels).Observe(float64(sizeInBytes))
+}
+
+func (m httpHandlerMetric) ObserveDuration(duration time.Duration) {
+	m.metrics.durationSeconds.With(m.labels).Observe(duration.Seconds())
+}
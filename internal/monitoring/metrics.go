@@ -1,48 +1,121 @@
 package monitoring
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
-var (
-	apiRequestsInFlight = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "api_requests_in_flight",
-			Help: "Number of api requests in flight",
-		},
-		[]string{"ID"})
-
-	apiRequestDuration = promauto.NewHistogramVec(
-
-		prometheus.HistogramOpts{
-			Name: "api_request_duration_seconds",
-			Help: "Duration of api requests in seconds",
-		},
-		[]string{"ID"},
-	)
+const (
+	// apiRequestOK represents the indicator that the api request was successful.
+	apiRequestOK = "ok"
+	// apiRequestFailed represents the indicator that the api request has failed.
+	apiRequestFailed = "failed"
+	// apiRequestCanceled represents the indicator that the api request was canceled by the client.
+	apiRequestCanceled = "canceled"
 )
 
 type APIRequestMetric struct {
-	id    string
-	start time.Time
+	reg      *Registry
+	id       string
+	start    time.Time
+	exemplar prometheus.Labels
 }
 
-func NewAPIRequestMetric(id int) APIRequestMetric {
+func (r *Registry) NewAPIRequestMetric(id int) APIRequestMetric {
 	return APIRequestMetric{
-		id:    fmt.Sprintf("%d", id),
+		reg:   r,
+		id:    r.idLabel.Value(fmt.Sprintf("%d", id)),
 		start: time.Now(),
 	}
 }
 
-func (m APIRequestMetric) Begin() func() {
-	apiRequestsInFlight.WithLabelValues(m.id).Inc()
+// WithExemplar attaches the request and trace IDs as an exemplar to the
+// latency observation. Empty IDs are left out.
+func (m APIRequestMetric) WithExemplar(requestID, traceID string) APIRequestMetric {
+	exemplar := prometheus.Labels{}
+	if traceID != "" && utf8.ValidString(traceID) {
+		exemplar["trace_id"] = traceID
+	}
+
+	if requestID != "" && utf8.ValidString(requestID) {
+		exemplar["request_id"] = requestID
+		if exemplarRunes(exemplar) > prometheus.ExemplarMaxRunes {
+			delete(exemplar, "request_id")
+		}
+	}
+
+	if len(exemplar) > 0 && exemplarRunes(exemplar) <= prometheus.ExemplarMaxRunes {
+		m.exemplar = exemplar
+	}
+
+	return m
+}
+
+// Begin starts monitoring the request. The returned function must be called
+// with the status code of the response, or the error the request ended with.
+//
+// The series of an ID are deleted once it is evicted by the cardinality
+// limiter, and must not be recreated by the requests in flight then: those
+// are counted as other when they end.
+func (m APIRequestMetric) Begin() func(statusCode int, err error) {
+	m.reg.idSeries.RLock()
+	m.id = m.reg.idLabel.current(m.id)
+	inFlight := m.reg.apiRequestsInFlight.WithLabelValues(m.id)
+	duration := m.reg.apiRequestDuration.WithLabelValues(m.id)
+	inFlight.Inc()
+	m.reg.idSeries.RUnlock()
+
+	return func(statusCode int, err error) {
+		seconds := time.Since(m.start).Seconds()
+		inFlight.Dec()
+
+		m.reg.idSeries.RLock()
+		defer m.reg.idSeries.RUnlock()
+
+		id := m.reg.idLabel.current(m.id)
+		if id != m.id {
+			duration = m.reg.apiRequestDuration.WithLabelValues(id)
+		}
+
+		m.observe(duration, seconds)
+		m.reg.apiRequestsTotal.WithLabelValues(id, strconv.Itoa(statusCode), apiRequestResult(statusCode, err)).Inc()
+	}
+}
+
+func (m APIRequestMetric) observe(observer prometheus.Observer, seconds float64) {
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && m.exemplar != nil {
+		eo.ObserveWithExemplar(seconds, m.exemplar)
+		return
+	}
+
+	observer.Observe(seconds)
+}
+
+func exemplarRunes(labels prometheus.Labels) int {
+	var runes int
+	for name, value := range labels {
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+
+	return runes
+}
 
-	return func() {
-		apiRequestsInFlight.WithLabelValues(m.id).Dec()
-		apiRequestDuration.WithLabelValues(m.id).Observe(time.Since(m.start).Seconds())
+// apiRequestResult classifies the request the same way shared-go-libs
+// classifies third-party api calls, treating server errors as failures.
+func apiRequestResult(statusCode int, err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return apiRequestCanceled
+	case err != nil, statusCode >= http.StatusInternalServerError:
+		return apiRequestFailed
+	default:
+		return apiRequestOK
 	}
 }
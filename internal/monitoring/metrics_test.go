@@ -0,0 +1,119 @@
+package monitoring
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// TestAPIRequestMetricEvictedInFlight checks that a request whose ID is
+// evicted while it is in flight doesn't recreate the deleted series.
+func TestAPIRequestMetricEvictedInFlight(t *testing.T) {
+	r := NewRegistry(WithIDLabel(CardinalityOptions{MaxValues: 1}))
+
+	end := r.NewAPIRequestMetric(1).Begin()
+
+	// ID 2 is hit more often than 1, and replaces it.
+	for i := 0; i < 3; i++ {
+		r.NewAPIRequestMetric(2).Begin()(http.StatusOK, nil)
+	}
+
+	end(http.StatusOK, nil)
+
+	want := []string{"2", OtherLabelValue}
+	for _, family := range []string{"api_requests_in_flight", "api_request_duration_seconds", "api_requests_total"} {
+		if ids := seriesIDs(t, r, family); !slices.Equal(ids, want) {
+			t.Errorf("%s IDs = %v, want %v", family, ids, want)
+		}
+	}
+}
+
+func TestConfigureIDLabelDeletesDroppedSeries(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     CardinalityOptions
+		requests []int
+		reload   CardinalityOptions
+		want     []string
+	}{
+		{
+			name:     "unchanged options",
+			opts:     CardinalityOptions{MaxValues: 2},
+			requests: []int{1, 2, 3},
+			reload:   CardinalityOptions{MaxValues: 2},
+			want:     []string{"1", "2", OtherLabelValue},
+		},
+		{
+			name:     "changed max values forgets tracked IDs",
+			opts:     CardinalityOptions{MaxValues: 2},
+			requests: []int{1, 2, 3},
+			reload:   CardinalityOptions{MaxValues: 3},
+			want:     []string{OtherLabelValue},
+		},
+		{
+			name:     "shrunk allow-list",
+			opts:     CardinalityOptions{AllowList: []string{"1", "2"}},
+			requests: []int{1, 2, 3},
+			reload:   CardinalityOptions{AllowList: []string{"2"}},
+			want:     []string{"2", OtherLabelValue},
+		},
+		{
+			name:     "limit enabled",
+			requests: []int{1, 2},
+			reload:   CardinalityOptions{AllowList: []string{"1"}},
+			want:     []string{"1"},
+		},
+		{
+			name:     "limit disabled",
+			opts:     CardinalityOptions{AllowList: []string{"1"}},
+			requests: []int{1, 2},
+			reload:   CardinalityOptions{},
+			want:     []string{"1", OtherLabelValue},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(WithIDLabel(tt.opts))
+			for _, id := range tt.requests {
+				r.NewAPIRequestMetric(id).Begin()(http.StatusOK, nil)
+			}
+
+			r.ConfigureIDLabel(tt.reload)
+
+			for _, family := range []string{"api_requests_in_flight", "api_request_duration_seconds", "api_requests_total"} {
+				if ids := seriesIDs(t, r, family); !slices.Equal(ids, tt.want) {
+					t.Errorf("%s IDs = %v, want %v", family, ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// seriesIDs returns the sorted ID label values of the series of family.
+func seriesIDs(t *testing.T, r *Registry, family string) []string {
+	t.Helper()
+
+	families, err := r.Gatherer().Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %s", err)
+	}
+
+	var ids []string
+	for _, f := range families {
+		if f.GetName() != family {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "ID" {
+					ids = append(ids, l.GetValue())
+				}
+			}
+		}
+	}
+	slices.Sort(ids)
+
+	return ids
+}
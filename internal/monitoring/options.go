@@ -0,0 +1,160 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
+)
+
+const (
+	defaultAddress           = ":9090"
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+)
+
+type Option func(*options)
+
+type options struct {
+	address           string
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	defaultRegistry   bool
+	registryOptions   []RegistryOption
+	tls               tlsconfig.Options
+	webConfigFile     string
+	authExemptHealth  bool
+}
+
+func defaultOptions() options {
+	return options{
+		address:           defaultAddress,
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		readTimeout:       defaultReadTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		idleTimeout:       defaultIdleTimeout,
+		maxHeaderBytes:    defaultMaxHeaderBytes,
+		authExemptHealth:  true,
+	}
+}
+
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readHeaderTimeout = timeout
+	}
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = timeout
+	}
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = timeout
+	}
+}
+
+func WithMaxHeaderBytes(n int) Option {
+	return func(o *options) {
+		o.maxHeaderBytes = n
+	}
+}
+
+// WithDefaultRegistry makes the server register and expose its metrics on
+// the process-global prometheus registry instead of a dedicated one.
+func WithDefaultRegistry() Option {
+	return func(o *options) {
+		o.defaultRegistry = true
+	}
+}
+
+// WithRegistryOptions configures the metrics of the server registry.
+func WithRegistryOptions(opts ...RegistryOption) Option {
+	return func(o *options) {
+		o.registryOptions = append(o.registryOptions, opts...)
+	}
+}
+
+// WithTLS serves the monitoring endpoints over TLS. With a client CA file,
+// metrics are only served to clients presenting a certificate signed by it,
+// while the health endpoints stay reachable without one.
+func WithTLS(opts tlsconfig.Options) Option {
+	return func(o *options) {
+		o.tls = opts
+	}
+}
+
+// WithWebConfigFile requires the requests to authenticate with the basic
+// auth users or bearer tokens listed in the web configuration file at path.
+func WithWebConfigFile(path string) Option {
+	return func(o *options) {
+		o.webConfigFile = path
+	}
+}
+
+// WithAuthExemptHealth serves the health endpoints without authentication,
+// so that probes don't need credentials. They are exempt by default.
+func WithAuthExemptHealth(exempt bool) Option {
+	return func(o *options) {
+		o.authExemptHealth = exempt
+	}
+}
+
+func (o options) validate() error {
+	var errs []error
+
+	if o.address == "" {
+		errs = append(errs, errors.New("address must not be empty"))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read header timeout", o.readHeaderTimeout},
+		{"read timeout", o.readTimeout},
+		{"write timeout", o.writeTimeout},
+		{"idle timeout", o.idleTimeout},
+	}
+
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.name, d.value))
+		}
+	}
+
+	if o.maxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max header bytes must not be negative, got %d", o.maxHeaderBytes))
+	}
+
+	if err := o.tls.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+
+	if err := newRegistryOptions(o.registryOptions).validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,315 @@
+package monitoring
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Registry holds the metrics of a single Server. Unless it was created with
+// NewDefaultRegistry, it is isolated from the process-global registry.
+type Registry struct {
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+
+	apiRequestsInFlight *prometheus.GaugeVec
+	apiRequestDuration  *prometheus.HistogramVec
+	apiRequestsTotal    *prometheus.CounterVec
+	labelValuesFolded   *prometheus.CounterVec
+	http                httpMetrics
+	panicsTotal         *prometheus.CounterVec
+	rateLimitedTotal    *prometheus.CounterVec
+	concurrencyLimit    prometheus.Gauge
+	shedTotal           *prometheus.CounterVec
+	configReloadSuccess prometheus.Gauge
+	configLastReload    prometheus.Gauge
+	authFailures        *prometheus.CounterVec
+
+	idLabel     *CardinalityLimiter
+	diagnostics *diagnosingGatherer
+
+	// idSeries orders the updates of the api request series of an ID before
+	// or after their deletion once the ID is evicted, never in between.
+	idSeries sync.RWMutex
+}
+
+type RegistryOption func(*registryOptions)
+
+type registryOptions struct {
+	latency LatencyHistogram
+	idLabel CardinalityOptions
+}
+
+func newRegistryOptions(opts []RegistryOption) registryOptions {
+	o := registryOptions{
+		idLabel: CardinalityOptions{
+			MaxValues: defaultMaxIDLabelValues,
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func (o registryOptions) validate() error {
+	if err := o.latency.Validate(); err != nil {
+		return fmt.Errorf("latency histogram: %w", err)
+	}
+
+	if o.idLabel.MaxValues < 0 {
+		return fmt.Errorf("ID label max values must not be negative, got %d", o.idLabel.MaxValues)
+	}
+
+	return nil
+}
+
+// WithLatencyHistogram configures the buckets of the request latency histogram.
+func WithLatencyHistogram(h LatencyHistogram) RegistryOption {
+	return func(o *registryOptions) {
+		o.latency = h
+	}
+}
+
+// WithIDLabel configures the limits applied to the ID label of the api request metrics.
+func WithIDLabel(opts CardinalityOptions) RegistryOption {
+	return func(o *registryOptions) {
+		o.idLabel = opts
+	}
+}
+
+// NewRegistry returns a registry backed by a dedicated prometheus.Registry
+// with the Go runtime and process collectors registered.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return newRegistry(reg, reg, opts)
+}
+
+// NewDefaultRegistry returns a registry backed by the process-global
+// prometheus registerer and gatherer.
+func NewDefaultRegistry(opts ...RegistryOption) *Registry {
+	return newRegistry(prometheus.DefaultRegisterer, prometheus.DefaultGatherer, opts)
+}
+
+func newRegistry(registerer prometheus.Registerer, gatherer prometheus.Gatherer, opts []RegistryOption) *Registry {
+	o := newRegistryOptions(opts)
+
+	r := &Registry{
+		registerer: registerer,
+		gatherer:   gatherer,
+	}
+
+	r.apiRequestsInFlight = register(r.registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "api_requests_in_flight",
+			Help: "Number of api requests in flight",
+		},
+		[]string{"ID"}))
+
+	r.apiRequestDuration = register(r.registerer, prometheus.NewHistogramVec(
+		o.latency.opts("api_request_duration_seconds", "Duration of api requests in seconds"),
+		[]string{"ID"}))
+
+	r.apiRequestsTotal = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_requests_total",
+			Help: "Number of api requests by status code and result",
+		},
+		[]string{"ID", "code", "result"}))
+
+	r.labelValuesFolded = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "metric_label_values_folded_total",
+			Help: "Number of label values folded into the other bucket by the cardinality limiter",
+		},
+		[]string{"label"}))
+
+	r.http = newHTTPMetrics(r.registerer)
+
+	r.panicsTotal = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_panics_total",
+			Help: "Number of panics recovered from while serving HTTP requests",
+		},
+		[]string{"route"}))
+
+	r.rateLimitedTotal = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limited_requests_total",
+			Help: "Number of requests rejected for exceeding the rate limits",
+		},
+		[]string{"route"}))
+
+	r.concurrencyLimit = register(r.registerer, prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "concurrency_limit",
+			Help: "Maximum number of requests served at once, 0 when unlimited",
+		}))
+
+	r.shedTotal = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shed_requests_total",
+			Help: "Number of requests shed for exceeding the concurrency limit",
+		},
+		[]string{"route"}))
+
+	r.configReloadSuccess = register(r.registerer, prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_reload_success",
+			Help: "Whether the last configuration reload attempt was successful",
+		}))
+
+	r.configLastReload = register(r.registerer, prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		}))
+
+	r.configReloadSuccess.Set(1)
+	r.configLastReload.SetToCurrentTime()
+
+	r.authFailures = register(r.registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "monitoring_auth_failures_total",
+			Help: "Number of requests to the monitoring server rejected for missing or invalid credentials",
+		},
+		[]string{"reason"}))
+
+	gatherErrors := register(r.registerer, prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "metrics_gather_errors_total",
+			Help: "Number of errors returned while gathering metrics",
+		}))
+
+	r.diagnostics = newDiagnosingGatherer(r.gatherer, gatherErrors)
+
+	r.idLabel = r.NewCardinalityLimiter("ID", o.idLabel)
+	r.idLabel.evicted = r.deleteIDSeries
+
+	return r
+}
+
+// deleteIDSeries deletes the api request series of an ID no longer tracked by
+// the cardinality limiter, whose requests are now counted as other.
+func (r *Registry) deleteIDSeries(id string) {
+	r.idSeries.Lock()
+	defer r.idSeries.Unlock()
+
+	labels := prometheus.Labels{"ID": id}
+
+	r.apiRequestsInFlight.DeletePartialMatch(labels)
+	r.apiRequestDuration.DeletePartialMatch(labels)
+	r.apiRequestsTotal.DeletePartialMatch(labels)
+}
+
+func (r *Registry) Registerer() prometheus.Registerer {
+	return r.registerer
+}
+
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.gatherer
+}
+
+// Handler returns the handler exposing the metrics gathered from this
+// registry. The OpenMetrics format, which carries exemplars, is served to
+// scrapers negotiating it.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		r.registerer,
+		promhttp.HandlerFor(r.diagnostics, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}),
+	)
+}
+
+// GatherErrorsHandler returns the handler listing the errors met while
+// gathering metrics for Handler.
+func (r *Registry) GatherErrorsHandler() http.Handler {
+	return r.diagnostics
+}
+
+// ConfigureIDLabel changes the limits applied to the ID label of the api
+// request metrics, deleting the series of the IDs no longer let through.
+func (r *Registry) ConfigureIDLabel(opts CardinalityOptions) {
+	r.idLabel.Configure(opts)
+
+	for _, id := range r.idSeriesValues() {
+		if r.idLabel.current(id) != id {
+			r.deleteIDSeries(id)
+		}
+	}
+}
+
+// idSeriesValues returns the IDs the api request series exist for.
+func (r *Registry) idSeriesValues() []string {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		r.apiRequestsInFlight.Collect(ch)
+		r.apiRequestDuration.Collect(ch)
+		r.apiRequestsTotal.Collect(ch)
+		close(ch)
+	}()
+
+	seen := make(map[string]struct{})
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			continue
+		}
+
+		for _, l := range pb.GetLabel() {
+			if l.GetName() == "ID" {
+				seen[l.GetValue()] = struct{}{}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// ObserveConfigReload records the outcome of a configuration reload.
+func (r *Registry) ObserveConfigReload(success bool) {
+	if !success {
+		r.configReloadSuccess.Set(0)
+		return
+	}
+
+	r.configReloadSuccess.Set(1)
+	r.configLastReload.SetToCurrentTime()
+}
+
+// register registers c and returns it. If an equal collector is already
+// registered, which happens when several registries share the global one,
+// the existing collector is returned instead.
+func register[T prometheus.Collector](registerer prometheus.Registerer, c T) T {
+	err := registerer.Register(c)
+	if err == nil {
+		return c
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+
+	panic(err)
+}
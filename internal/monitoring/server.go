@@ -2,13 +2,15 @@ package monitoring
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/sliide/shared-go-libs/metric/prometheus"
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
 )
 
 const (
@@ -16,26 +18,121 @@ const (
 )
 
 type Server struct {
-	server  *http.Server
-	m       sync.Mutex
-	serving int32
+	server   *http.Server
+	registry *Registry
+	health   *health
+	m        sync.Mutex
+	serving  int32
 }
 
-func NewServer(address string) *Server {
+func NewServer(opts ...Option) (*Server, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := o.validate(); err != nil {
+		return nil, fmt.Errorf("invalid monitoring server options: %w", err)
+	}
+
+	// The web config is loaded before metrics are registered, possibly on
+	// the default registry, so that failing leaves nothing behind.
+	var webConfig WebConfig
+	if o.webConfigFile != "" {
+		var err error
+		webConfig, err = loadWebConfig(o.webConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("load monitoring web config: %w", err)
+		}
+	}
+
+	var registry *Registry
+	if o.defaultRegistry {
+		registry = NewDefaultRegistry(o.registryOptions...)
+	} else {
+		registry = NewRegistry(o.registryOptions...)
+	}
+
+	health := newHealth()
+
+	var auth *authenticator
+	if o.webConfigFile != "" {
+		var err error
+		auth, err = newAuthenticator(webConfig, registry.authFailures)
+		if err != nil {
+			return nil, fmt.Errorf("configure monitoring server auth: %w", err)
+		}
+	}
+
 	r := chi.NewRouter()
 
-	r.Get("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		prometheus.Handler().ServeHTTP(w, r)
-	}))
+	r.Group(func(r chi.Router) {
+		if auth != nil && !o.authExemptHealth {
+			r.Use(auth.middleware)
+		}
+
+		r.Get("/healthz", health.livenessHandler)
+		r.Get("/readyz", health.readinessHandler)
+	})
+
+	r.Group(func(r chi.Router) {
+		if o.tls.ClientCAFile != "" {
+			r.Use(requireClientCert)
+		}
+
+		if auth != nil {
+			r.Use(auth.middleware)
+		}
+
+		r.Get("/metrics", registry.Handler().ServeHTTP)
+		r.Get("/debug/metrics/errors", registry.GatherErrorsHandler().ServeHTTP)
+	})
 
 	s := &Server{
 		server: &http.Server{
-			Addr:    address,
-			Handler: r,
+			Addr:              o.address,
+			Handler:           r,
+			ReadHeaderTimeout: o.readHeaderTimeout,
+			ReadTimeout:       o.readTimeout,
+			WriteTimeout:      o.writeTimeout,
+			IdleTimeout:       o.idleTimeout,
+			MaxHeaderBytes:    o.maxHeaderBytes,
 		},
+		registry: registry,
+		health:   health,
 	}
 
-	return s
+	if o.tls.Enabled() {
+		o.tls.ClientAuth = tls.VerifyClientCertIfGiven
+
+		var err error
+		s.server.TLSConfig, err = tlsconfig.NewServerConfig(o.tls)
+		if err != nil {
+			return nil, fmt.Errorf("configure monitoring server tls: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Server) Registry() *Registry {
+	return s.registry
+}
+
+// AddLivenessCheck registers a check reported by /healthz.
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.health.addLivenessCheck(name, check)
+}
+
+// AddReadinessCheck registers a check reported by /readyz.
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.health.addReadinessCheck(name, check)
+}
+
+// BeginShutdown marks the server as not ready, while it keeps serving
+// metrics until GracefulStop is called.
+func (s *Server) BeginShutdown() {
+	s.health.beginShutdown()
 }
 
 func (s *Server) ListenAndServe() error {
@@ -47,6 +144,10 @@ func (s *Server) ListenAndServe() error {
 		atomic.StoreInt32(&s.serving, 0)
 	}()
 
+	if s.server.TLSConfig != nil {
+		return s.server.ListenAndServeTLS("", "")
+	}
+
 	return s.server.ListenAndServe()
 }
 
@@ -54,9 +155,26 @@ func (s *Server) GracefulStop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
 	defer cancel()
 
+	return s.Shutdown(ctx)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
 func (s *Server) Serving() bool {
 	return atomic.LoadInt32(&s.serving) == 1
 }
+
+// requireClientCert rejects requests that were not made with a client
+// certificate verified against the client CA bundle.
+func requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "client certificate required", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
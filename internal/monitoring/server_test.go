@@ -0,0 +1,149 @@
+package monitoring
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewServerInvalidWebConfigRegistersNothing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.yaml")
+	if err := os.WriteFile(path, []byte("bearer_tokens: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewServer(WithDefaultRegistry(), WithWebConfigFile(path)); err == nil {
+		t.Fatal("NewServer() with an invalid web config returned no error")
+	}
+
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %s", err)
+	}
+
+	for _, mf := range mfs {
+		if mf.GetName() == "metrics_gather_errors_total" {
+			t.Fatal("failed NewServer() registered metrics on the default registry")
+		}
+	}
+}
+
+func TestNewServerHealthExemptFromAuthByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.yaml")
+	if err := os.WriteFile(path, []byte("bearer_tokens: [secret]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(WithWebConfigFile(path))
+	if err != nil {
+		t.Fatalf("NewServer() error = %s", err)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/healthz", wantStatus: http.StatusOK},
+		{path: "/metrics", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+// TestNewServerIsolatedRegistries runs servers side by side, as parallel
+// tests do, each exposing only its own metrics.
+func TestNewServerIsolatedRegistries(t *testing.T) {
+	for _, id := range []int{1, 2} {
+		id := id
+		t.Run(fmt.Sprint(id), func(t *testing.T) {
+			t.Parallel()
+
+			s, err := NewServer()
+			if err != nil {
+				t.Fatalf("NewServer() error = %s", err)
+			}
+			s.Registry().NewAPIRequestMetric(id).Begin()(http.StatusOK, nil)
+
+			body := scrape(t, s)
+			if want := fmt.Sprintf(`api_requests_total{ID="%d"`, id); !strings.Contains(body, want) {
+				t.Errorf("metrics do not contain %s", want)
+			}
+			if other := fmt.Sprintf(`api_requests_total{ID="%d"`, 3-id); strings.Contains(body, other) {
+				t.Errorf("metrics contain %s of the other server", other)
+			}
+		})
+	}
+}
+
+func TestNewServerDefaultRegistry(t *testing.T) {
+	defaultRegistry := prometheus.NewRegistry()
+	withDefaultRegistry(t, defaultRegistry)
+
+	marker := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_default_registry_marker_total",
+		Help: "Counter registered on the default registry by the tests",
+	})
+	defaultRegistry.MustRegister(marker)
+	marker.Inc()
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantDefault bool
+	}{
+		{name: "isolated"},
+		{name: "default", opts: []Option{WithDefaultRegistry()}, wantDefault: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServer(tt.opts...)
+			if err != nil {
+				t.Fatalf("NewServer() error = %s", err)
+			}
+
+			body := scrape(t, s)
+			if got := strings.Contains(body, "test_default_registry_marker_total"); got != tt.wantDefault {
+				t.Errorf("metrics contain the default registry marker = %t, want %t", got, tt.wantDefault)
+			}
+			if !strings.Contains(body, "metrics_gather_errors_total") {
+				t.Error("metrics do not contain those of the server")
+			}
+		})
+	}
+}
+
+func scrape(t *testing.T, s *Server) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	return rec.Body.String()
+}
+
+// withDefaultRegistry replaces the process-global registry for the test.
+func withDefaultRegistry(t *testing.T, r *prometheus.Registry) {
+	registerer, gatherer := prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+	prometheus.DefaultRegisterer, prometheus.DefaultGatherer = r, r
+
+	t.Cleanup(func() {
+		prometheus.DefaultRegisterer, prometheus.DefaultGatherer = registerer, gatherer
+	})
+}
@@ -0,0 +1,134 @@
+package monitoring
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/tlsconfig/tlsconfigtest"
+)
+
+func TestServerTLS(t *testing.T) {
+	ca := tlsconfigtest.NewAuthority(t)
+	certPEM, keyPEM := ca.Issue(t, 10, x509.ExtKeyUsageServerAuth)
+	clientCertPEM, clientKeyPEM := ca.Issue(t, 30, x509.ExtKeyUsageClientAuth)
+
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	if err != nil {
+		t.Fatalf("load client key pair: %s", err)
+	}
+
+	tests := []struct {
+		name       string
+		clientCA   bool
+		clientCert bool
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "handshake",
+			path:       "/metrics",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "metrics without client certificate",
+			clientCA:   true,
+			path:       "/metrics",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "metrics with client certificate",
+			clientCA:   true,
+			clientCert: true,
+			path:       "/metrics",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "health without client certificate",
+			clientCA:   true,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ca.Files(t, certPEM, keyPEM)
+			if !tt.clientCA {
+				opts.ClientCAFile = ""
+			}
+
+			address := freeAddress(t)
+			server, err := NewServer(WithAddress(address), WithTLS(opts))
+			if err != nil {
+				t.Fatalf("NewServer() error = %s", err)
+			}
+
+			served := make(chan error, 1)
+			go func() { served <- server.ListenAndServe() }()
+			t.Cleanup(func() {
+				_ = server.GracefulStop()
+				if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+					t.Errorf("ListenAndServe() error = %v", err)
+				}
+			})
+
+			clientConfig := &tls.Config{RootCAs: ca.Pool()}
+			if tt.clientCert {
+				clientConfig.Certificates = []tls.Certificate{clientCert}
+			}
+
+			client := &http.Client{
+				Timeout:   5 * time.Second,
+				Transport: &http.Transport{TLSClientConfig: clientConfig},
+			}
+			t.Cleanup(client.CloseIdleConnections)
+
+			resp := getWhenListening(t, client, "https://"+address+tt.path)
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.TLS == nil || !resp.TLS.HandshakeComplete {
+				t.Error("response was not served over TLS")
+			}
+		})
+	}
+}
+
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// getWhenListening retries the request until the server listens.
+func getWhenListening(t *testing.T, client *http.Client, url string) *http.Response {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get(url)
+		if err == nil {
+			return resp
+		}
+
+		var opErr *net.OpError
+		if !errors.As(err, &opErr) || opErr.Op != "dial" || time.Now().After(deadline) {
+			t.Fatalf("GET %s: %s", url, err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLogOptions configures the access log. Levels are chosen by the class
+// of the response status code, and only SuccessSampleRate of the 2xx
+// responses are logged.
+type AccessLogOptions struct {
+	InformationalLevel logrus.Level
+	SuccessLevel       logrus.Level
+	RedirectionLevel   logrus.Level
+	ClientErrorLevel   logrus.Level
+	ServerErrorLevel   logrus.Level
+
+	SuccessSampleRate float64
+}
+
+func DefaultAccessLogOptions() AccessLogOptions {
+	return AccessLogOptions{
+		InformationalLevel: logrus.InfoLevel,
+		SuccessLevel:       logrus.InfoLevel,
+		RedirectionLevel:   logrus.InfoLevel,
+		ClientErrorLevel:   logrus.WarnLevel,
+		ServerErrorLevel:   logrus.ErrorLevel,
+		SuccessSampleRate:  1,
+	}
+}
+
+func (o AccessLogOptions) Validate() error {
+	levels := map[string]logrus.Level{
+		"informational": o.InformationalLevel,
+		"success":       o.SuccessLevel,
+		"redirection":   o.RedirectionLevel,
+		"client error":  o.ClientErrorLevel,
+		"server error":  o.ServerErrorLevel,
+	}
+
+	for class, level := range levels {
+		if level < logrus.ErrorLevel || level > logrus.TraceLevel {
+			return fmt.Errorf("%s level must be between error and trace, got %q", class, level)
+		}
+	}
+
+	if o.SuccessSampleRate < 0 || o.SuccessSampleRate > 1 {
+		return fmt.Errorf("success sample rate must be between 0 and 1, got %v", o.SuccessSampleRate)
+	}
+
+	return nil
+}
+
+func (o AccessLogOptions) level(statusCode int) logrus.Level {
+	switch {
+	case statusCode >= http.StatusInternalServerError:
+		return o.ServerErrorLevel
+	case statusCode >= http.StatusBadRequest:
+		return o.ClientErrorLevel
+	case statusCode >= http.StatusMultipleChoices:
+		return o.RedirectionLevel
+	case statusCode >= http.StatusOK:
+		return o.SuccessLevel
+	default:
+		return o.InformationalLevel
+	}
+}
+
+func (o AccessLogOptions) sampled(statusCode int) bool {
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		return true
+	}
+
+	return o.SuccessSampleRate >= 1 || rand.Float64() < o.SuccessSampleRate
+}
+
+// accessLogger logs the completion of every request. Its options can be
+// replaced while the server is running.
+type accessLogger struct {
+	opts atomic.Pointer[AccessLogOptions]
+}
+
+func newAccessLogger(opts AccessLogOptions) *accessLogger {
+	l := &accessLogger{}
+	l.Configure(opts)
+
+	return l
+}
+
+func (l *accessLogger) Configure(opts AccessLogOptions) {
+	l.opts.Store(&opts)
+}
+
+func (l *accessLogger) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := wrapResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		opts := l.opts.Load()
+		status := rw.Status()
+		if !opts.sampled(status) {
+			return
+		}
+
+		logger(r).WithFields(logrus.Fields{
+			"status":      status,
+			"bytes":       rw.BytesWritten(),
+			"duration":    time.Since(start).Seconds(),
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+			"route":       routePattern(r),
+		}).Log(opts.level(status), "Request completed")
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// entryRecorder is a logrus hook keeping the entries logged.
+type entryRecorder struct {
+	m       sync.Mutex
+	entries []*logrus.Entry
+}
+
+func (h *entryRecorder) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *entryRecorder) Fire(e *logrus.Entry) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.entries = append(h.entries, e)
+
+	return nil
+}
+
+// newRecordedLogger returns a logger logging every level into the returned
+// recorder only.
+func newRecordedLogger() (*logrus.Entry, *entryRecorder) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	l.SetLevel(logrus.TraceLevel)
+
+	h := &entryRecorder{}
+	l.AddHook(h)
+
+	return logrus.NewEntry(l), h
+}
+
+// serveAccessLogged serves a request answered with status through the access
+// logger, logging into log.
+func serveAccessLogged(l *accessLogger, log *logrus.Entry, status int) {
+	handler := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	r := newRequestWithLogger(httptest.NewRequest(http.MethodGet, "/", nil), log)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestAccessLoggerLevels(t *testing.T) {
+	opts := AccessLogOptions{
+		InformationalLevel: logrus.TraceLevel,
+		SuccessLevel:       logrus.DebugLevel,
+		RedirectionLevel:   logrus.InfoLevel,
+		ClientErrorLevel:   logrus.WarnLevel,
+		ServerErrorLevel:   logrus.ErrorLevel,
+		SuccessSampleRate:  1,
+	}
+
+	tests := []struct {
+		status    int
+		wantLevel logrus.Level
+	}{
+		{status: http.StatusSwitchingProtocols, wantLevel: logrus.TraceLevel},
+		{status: http.StatusOK, wantLevel: logrus.DebugLevel},
+		{status: http.StatusNoContent, wantLevel: logrus.DebugLevel},
+		{status: http.StatusFound, wantLevel: logrus.InfoLevel},
+		{status: http.StatusNotFound, wantLevel: logrus.WarnLevel},
+		{status: http.StatusTooManyRequests, wantLevel: logrus.WarnLevel},
+		{status: http.StatusInternalServerError, wantLevel: logrus.ErrorLevel},
+		{status: http.StatusServiceUnavailable, wantLevel: logrus.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			log, recorded := newRecordedLogger()
+			serveAccessLogged(newAccessLogger(opts), log, tt.status)
+
+			if len(recorded.entries) != 1 {
+				t.Fatalf("%d entries logged, want 1", len(recorded.entries))
+			}
+
+			e := recorded.entries[0]
+			if e.Level != tt.wantLevel {
+				t.Errorf("level = %s, want %s", e.Level, tt.wantLevel)
+			}
+			if e.Data["status"] != tt.status {
+				t.Errorf("status field = %v, want %d", e.Data["status"], tt.status)
+			}
+		})
+	}
+}
+
+func TestAccessLoggerSampling(t *testing.T) {
+	const requests = 2000
+
+	tests := []struct {
+		name       string
+		sampleRate float64
+		status     int
+		wantMin    int
+		wantMax    int
+	}{
+		{name: "all successes", sampleRate: 1, status: http.StatusOK, wantMin: requests, wantMax: requests},
+		{name: "no successes", sampleRate: 0, status: http.StatusOK, wantMin: 0, wantMax: 0},
+		{name: "half of successes", sampleRate: 0.5, status: http.StatusOK, wantMin: requests * 4 / 10, wantMax: requests * 6 / 10},
+		{name: "client errors are not sampled", sampleRate: 0, status: http.StatusBadRequest, wantMin: requests, wantMax: requests},
+		{name: "server errors are not sampled", sampleRate: 0, status: http.StatusBadGateway, wantMin: requests, wantMax: requests},
+		{name: "redirections are not sampled", sampleRate: 0, status: http.StatusMovedPermanently, wantMin: requests, wantMax: requests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultAccessLogOptions()
+			opts.SuccessSampleRate = tt.sampleRate
+
+			l := newAccessLogger(opts)
+			log, recorded := newRecordedLogger()
+			for i := 0; i < requests; i++ {
+				serveAccessLogged(l, log, tt.status)
+			}
+
+			if n := len(recorded.entries); n < tt.wantMin || n > tt.wantMax {
+				t.Errorf("%d of %d requests logged, want between %d and %d", n, requests, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestAccessLoggerConfigure(t *testing.T) {
+	l := newAccessLogger(DefaultAccessLogOptions())
+	log, recorded := newRecordedLogger()
+
+	serveAccessLogged(l, log, http.StatusOK)
+
+	opts := DefaultAccessLogOptions()
+	opts.SuccessLevel = logrus.DebugLevel
+	l.Configure(opts)
+	serveAccessLogged(l, log, http.StatusOK)
+
+	opts.SuccessSampleRate = 0
+	l.Configure(opts)
+	serveAccessLogged(l, log, http.StatusOK)
+
+	var levels []logrus.Level
+	for _, e := range recorded.entries {
+		levels = append(levels, e.Level)
+	}
+
+	if len(levels) != 2 || levels[0] != logrus.InfoLevel || levels[1] != logrus.DebugLevel {
+		t.Errorf("levels logged = %v, want [info debug]", levels)
+	}
+}
+
+func TestAccessLogOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*AccessLogOptions)
+		wantErr bool
+	}{
+		{name: "default", modify: func(*AccessLogOptions) {}},
+		{name: "trace level", modify: func(o *AccessLogOptions) { o.SuccessLevel = logrus.TraceLevel }},
+		{name: "fatal level", modify: func(o *AccessLogOptions) { o.ServerErrorLevel = logrus.FatalLevel }, wantErr: true},
+		{name: "panic level", modify: func(o *AccessLogOptions) { o.ClientErrorLevel = logrus.PanicLevel }, wantErr: true},
+		{name: "negative sample rate", modify: func(o *AccessLogOptions) { o.SuccessSampleRate = -0.1 }, wantErr: true},
+		{name: "sample rate above one", modify: func(o *AccessLogOptions) { o.SuccessSampleRate = 1.1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultAccessLogOptions()
+			tt.modify(&opts)
+
+			if err := opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,21 +1,38 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"net/http"
 
 	"github.com/theskch/prometheus-issue/internal/monitoring"
 )
 
-type app struct{}
+type app struct {
+	metrics *monitoring.Registry
+}
 
 func (a app) Info(w http.ResponseWriter, r *http.Request, id int) {
-	metric := monitoring.NewAPIRequestMetric(id)
+	metric := a.metrics.NewAPIRequestMetric(id).WithExemplar(requestID(r), traceID(r))
 
+	// Anything that prevents the handler from completing, a panic included,
+	// is observed as an internal server error.
+	statusCode := http.StatusInternalServerError
 	end := metric.Begin()
-	defer end()
+	defer func() {
+		err := r.Context().Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			// The response is replaced by the timeout of handlerTimeouts.
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		end(statusCode, err)
+	}()
 
 	log := logger(r)
 	log.Info("Request received")
+
+	statusCode = http.StatusOK
 }
 
 func (a app) Ping(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+)
+
+func TestAppInfoRecordsSentStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctx        func() (context.Context, context.CancelFunc)
+		wantCode   string
+		wantResult string
+	}{
+		{
+			name:       "served",
+			ctx:        func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+			wantCode:   "200",
+			wantResult: "ok",
+		},
+		{
+			name: "deadline exceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			wantCode:   "503",
+			wantResult: "failed",
+		},
+		{
+			name: "client gone",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantCode:   "200",
+			wantResult: "canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := monitoring.NewRegistry()
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/info/7", nil).WithContext(ctx)
+			app{metrics: metrics}.Info(httptest.NewRecorder(), r, 7)
+
+			families, err := metrics.Gatherer().Gather()
+			if err != nil {
+				t.Fatalf("Gather() error = %s", err)
+			}
+
+			var got []map[string]string
+			for _, f := range families {
+				if f.GetName() != "api_requests_total" {
+					continue
+				}
+
+				for _, m := range f.GetMetric() {
+					labels := make(map[string]string)
+					for _, l := range m.GetLabel() {
+						labels[l.GetName()] = l.GetValue()
+					}
+					got = append(got, labels)
+				}
+			}
+
+			if len(got) != 1 || got[0]["code"] != tt.wantCode || got[0]["result"] != tt.wantResult {
+				t.Errorf("api_requests_total series = %v, want one with code %s and result %s", got, tt.wantCode, tt.wantResult)
+			}
+		})
+	}
+}
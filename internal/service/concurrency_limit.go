@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+)
+
+// ConcurrencyLimitOptions configures the number of requests served at once,
+// beyond which requests are shed with a 503. A zero Limit disables it.
+//
+// In adaptive mode Limit is the initial limit, tuned between MinLimit and
+// MaxLimit with additive increase, multiplicative decrease: every request
+// slower than LatencyThreshold or failing with a 5xx multiplies the limit by
+// BackoffRatio, while the others increase it by one when at least half of
+// the limit is in use.
+type ConcurrencyLimitOptions struct {
+	Limit int
+
+	Adaptive         bool
+	MinLimit         int
+	MaxLimit         int
+	LatencyThreshold time.Duration
+	BackoffRatio     float64
+}
+
+func DefaultConcurrencyLimitOptions() ConcurrencyLimitOptions {
+	return ConcurrencyLimitOptions{
+		MinLimit:         1,
+		MaxLimit:         1000,
+		LatencyThreshold: time.Second,
+		BackoffRatio:     0.9,
+	}
+}
+
+func (o ConcurrencyLimitOptions) Validate() error {
+	if o.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %d", o.Limit)
+	}
+
+	if !o.Adaptive {
+		return nil
+	}
+
+	if o.Limit == 0 {
+		return errors.New("adaptive mode requires an initial limit")
+	}
+
+	if o.MinLimit < 1 || o.MinLimit > o.Limit || o.Limit > o.MaxLimit {
+		return fmt.Errorf("limits must satisfy 1 <= min %d <= limit %d <= max %d", o.MinLimit, o.Limit, o.MaxLimit)
+	}
+
+	if o.LatencyThreshold <= 0 {
+		return fmt.Errorf("latency threshold must be positive, got %s", o.LatencyThreshold)
+	}
+
+	if o.BackoffRatio <= 0 || o.BackoffRatio >= 1 {
+		return fmt.Errorf("backoff ratio must be between 0 and 1, got %v", o.BackoffRatio)
+	}
+
+	return nil
+}
+
+// concurrencyLimiter sheds the requests exceeding the concurrency limit.
+type concurrencyLimiter struct {
+	opts    ConcurrencyLimitOptions
+	metrics *monitoring.Registry
+
+	m        sync.Mutex
+	limit    int
+	inFlight int
+}
+
+func newConcurrencyLimiter(metrics *monitoring.Registry, opts ConcurrencyLimitOptions) *concurrencyLimiter {
+	l := &concurrencyLimiter{
+		opts:    opts,
+		metrics: metrics,
+		limit:   opts.Limit,
+	}
+	metrics.SetConcurrencyLimit(opts.Limit)
+
+	return l
+}
+
+func (l *concurrencyLimiter) middleware(next http.Handler) http.Handler {
+	if l.opts.Limit == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire() {
+			l.metrics.ObserveShed(routePattern(r))
+			_ = renderError(w, r, http.StatusServiceUnavailable, "overloaded")
+			return
+		}
+
+		start := time.Now()
+		rw := wrapResponseWriter(w)
+
+		// Released in a defer so that panicking handlers don't leak a slot,
+		// counting them as failures.
+		failed := true
+		defer func() {
+			l.release(time.Since(start), failed)
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		failed = rw.Status() >= http.StatusInternalServerError
+	})
+}
+
+func (l *concurrencyLimiter) acquire() bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.inFlight >= l.limit {
+		return false
+	}
+
+	l.inFlight++
+
+	return true
+}
+
+func (l *concurrencyLimiter) release(latency time.Duration, failed bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	inFlight := l.inFlight
+	l.inFlight--
+
+	if !l.opts.Adaptive {
+		return
+	}
+
+	previous := l.limit
+	switch {
+	case failed || latency > l.opts.LatencyThreshold:
+		l.limit = max(l.opts.MinLimit, int(float64(l.limit)*l.opts.BackoffRatio))
+	case inFlight*2 >= l.limit:
+		// The limit is only raised when it is being used, otherwise it would
+		// grow without bound while the traffic is low.
+		l.limit = min(l.opts.MaxLimit, l.limit+1)
+	}
+
+	if l.limit != previous {
+		l.metrics.SetConcurrencyLimit(l.limit)
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+)
+
+func TestConcurrencyLimiterMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		inFlight   int
+		wantStatus []int
+	}{
+		{
+			name:       "disabled",
+			inFlight:   3,
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "within limit",
+			limit:      3,
+			inFlight:   3,
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "beyond limit is shed",
+			limit:      2,
+			inFlight:   3,
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newConcurrencyLimiter(monitoring.NewRegistry(), ConcurrencyLimitOptions{Limit: tt.limit})
+
+			// Every admitted request blocks until all of them were sent, so
+			// that they are in flight together.
+			release := make(chan struct{})
+			var started sync.WaitGroup
+			handler := l.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				started.Done()
+				<-release
+			}))
+
+			admitted := min(tt.inFlight, tt.limit)
+			if tt.limit == 0 {
+				admitted = tt.inFlight
+			}
+			started.Add(admitted)
+
+			recs := make([]*httptest.ResponseRecorder, tt.inFlight)
+			var served sync.WaitGroup
+			for i := range recs {
+				recs[i] = httptest.NewRecorder()
+				if i < admitted {
+					served.Add(1)
+					go func(rec *httptest.ResponseRecorder) {
+						defer served.Done()
+						handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+					}(recs[i])
+				}
+			}
+			started.Wait()
+
+			for i := admitted; i < tt.inFlight; i++ {
+				handler.ServeHTTP(recs[i], httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+
+			close(release)
+			served.Wait()
+
+			for i, rec := range recs {
+				if rec.Code != tt.wantStatus[i] {
+					t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimiterMiddlewareReleasesOnPanic(t *testing.T) {
+	l := newConcurrencyLimiter(monitoring.NewRegistry(), ConcurrencyLimitOptions{Limit: 1})
+	handler := l.middleware(http.HandlerFunc(panickingHandler))
+
+	func() {
+		defer func() { _ = recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if l.inFlight != 0 {
+		t.Errorf("in flight = %d after a panic, want 0", l.inFlight)
+	}
+}
+
+func TestConcurrencyLimiterAdaptive(t *testing.T) {
+	opts := ConcurrencyLimitOptions{
+		Limit:            10,
+		Adaptive:         true,
+		MinLimit:         8,
+		MaxLimit:         11,
+		LatencyThreshold: time.Second,
+		BackoffRatio:     0.9,
+	}
+
+	type request struct {
+		inFlight int
+		latency  time.Duration
+		failed   bool
+	}
+
+	tests := []struct {
+		name      string
+		adaptive  bool
+		requests  []request
+		wantLimit int
+	}{
+		{
+			name:      "fixed limit is not tuned",
+			requests:  []request{{inFlight: 1, failed: true}, {inFlight: 10}},
+			wantLimit: 10,
+		},
+		{
+			name:      "failure decreases",
+			adaptive:  true,
+			requests:  []request{{inFlight: 1, failed: true}},
+			wantLimit: 9,
+		},
+		{
+			name:      "slow request decreases",
+			adaptive:  true,
+			requests:  []request{{inFlight: 1, latency: 2 * time.Second}},
+			wantLimit: 9,
+		},
+		{
+			name:      "decrease stops at min",
+			adaptive:  true,
+			requests:  []request{{inFlight: 1, failed: true}, {inFlight: 1, failed: true}, {inFlight: 1, failed: true}},
+			wantLimit: 8,
+		},
+		{
+			name:      "used limit increases",
+			adaptive:  true,
+			requests:  []request{{inFlight: 5}},
+			wantLimit: 11,
+		},
+		{
+			name:      "increase stops at max",
+			adaptive:  true,
+			requests:  []request{{inFlight: 10}, {inFlight: 10}},
+			wantLimit: 11,
+		},
+		{
+			name:      "unused limit is kept",
+			adaptive:  true,
+			requests:  []request{{inFlight: 4}},
+			wantLimit: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.Adaptive = tt.adaptive
+			if err := opts.Validate(); err != nil {
+				t.Fatalf("Validate() error = %s", err)
+			}
+
+			l := newConcurrencyLimiter(monitoring.NewRegistry(), opts)
+			for _, req := range tt.requests {
+				l.inFlight = req.inFlight
+				l.release(req.latency, req.failed)
+			}
+
+			if l.limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", l.limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimitOptionsValidate(t *testing.T) {
+	adaptive := DefaultConcurrencyLimitOptions()
+	adaptive.Adaptive = true
+	adaptive.Limit = 10
+
+	tests := []struct {
+		name    string
+		modify  func(*ConcurrencyLimitOptions)
+		wantErr bool
+	}{
+		{name: "default", modify: func(o *ConcurrencyLimitOptions) { *o = DefaultConcurrencyLimitOptions() }},
+		{name: "adaptive", modify: func(*ConcurrencyLimitOptions) {}},
+		{name: "negative limit", modify: func(o *ConcurrencyLimitOptions) { o.Limit = -1 }, wantErr: true},
+		{name: "adaptive without limit", modify: func(o *ConcurrencyLimitOptions) { o.Limit = 0 }, wantErr: true},
+		{name: "limit below min", modify: func(o *ConcurrencyLimitOptions) { o.MinLimit = 11 }, wantErr: true},
+		{name: "limit above max", modify: func(o *ConcurrencyLimitOptions) { o.MaxLimit = 9 }, wantErr: true},
+		{name: "zero latency threshold", modify: func(o *ConcurrencyLimitOptions) { o.LatencyThreshold = 0 }, wantErr: true},
+		{name: "backoff ratio of one", modify: func(o *ConcurrencyLimitOptions) { o.BackoffRatio = 1 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := adaptive
+			tt.modify(&opts)
+
+			if err := opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+)
+
+const traceparentHeader = "traceparent"
+
+// traceID returns the trace ID of the W3C traceparent header, or an empty
+// string when the header is missing or malformed.
+func traceID(r *http.Request) string {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(strings.TrimSpace(r.Header.Get(traceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+
+	id := parts[1]
+	if len(id) != 32 || !isLowerHex(id) || strings.Trim(id, "0") == "" {
+		return ""
+	}
+
+	return id
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
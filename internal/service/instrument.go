@@ -0,0 +1,49 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sliide/shared-go-libs/metric/prometheus"
+)
+
+// instrument records the shared-go-libs HTTP metrics for every request,
+// labelled with the chi route pattern.
+func instrument(metrics prometheus.HTTPMetricer) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			m := metrics.WithHandler(methodLabel(r.Method), routePattern(r))
+			m.IncInFlight()
+			defer m.DecInFlight()
+
+			rw := wrapResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			m.Inc(rw.Status())
+			m.ObserveRequestSize(requestSize(r))
+			m.ObserveResponseSize(rw.BytesWritten())
+			m.ObserveDuration(time.Since(start))
+		})
+	}
+}
+
+// methodLabel folds non standard methods so clients cannot create new series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+func requestSize(r *http.Request) int64 {
+	if r.ContentLength < 0 {
+		return 0
+	}
+
+	return r.ContentLength
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"net/http"
+)
+
+// limitBody rejects requests announcing a body larger than maxBytes and caps
+// the bytes handlers can read from the others. Zero disables the limit.
+func limitBody(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				_ = renderError(w, r, http.StatusRequestEntityTooLarge, "request-too-large")
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
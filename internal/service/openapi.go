@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	spec "github.com/theskch/prometheus-issue/api"
+	"gopkg.in/yaml.v3"
+)
+
+// explorerPage lists the operations of the OpenAPI document and sends
+// requests to them. It loads nothing but the document, so that it works
+// without internet access.
+//
+//go:embed explorer.html
+var explorerPage []byte
+
+// openAPI serves the embedded OpenAPI document with its servers set to
+// serverURL.
+type openAPI struct {
+	serverURL string
+}
+
+// serverURL returns the base URL of a server listening on address. The
+// unspecified host of a server listening on all interfaces is served as
+// localhost.
+//
+// The URL is built from the configuration rather than from the Host header,
+// which is controlled by the client and would let a poisoned cache point the
+// explorer elsewhere.
+func serverURL(address string, tls bool) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, ""
+	}
+
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+
+	u := url.URL{Scheme: "http", Host: host, Path: baseURL}
+	if tls {
+		u.Scheme = "https"
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	return u.String()
+}
+
+func (o openAPI) yamlHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument(o.serverURL)
+	if err != nil {
+		renderOpenAPIError(w, r, err)
+		return
+	}
+
+	var payload bytes.Buffer
+	enc := yaml.NewEncoder(&payload)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		renderOpenAPIError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(payload.Bytes())
+}
+
+func (o openAPI) jsonHandler(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument(o.serverURL)
+	if err != nil {
+		renderOpenAPIError(w, r, err)
+		return
+	}
+
+	var v any
+	if err := doc.Decode(&v); err != nil {
+		renderOpenAPIError(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		renderOpenAPIError(w, r, err)
+		return
+	}
+
+	_ = renderRawJSON(w, http.StatusOK, payload)
+}
+
+func explorerHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy",
+		"default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	_, _ = w.Write(explorerPage)
+}
+
+func renderOpenAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	logger(r).WithError(err).Error("Failed to render the OpenAPI document")
+	_ = renderError(w, r, http.StatusInternalServerError, "internal-server-error")
+}
+
+// openAPIDocument returns the embedded OpenAPI document with its servers
+// replaced by url. The key order of the document is kept.
+func openAPIDocument(url string) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(spec.Spec, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("OpenAPI document is not a mapping")
+	}
+
+	var servers yaml.Node
+	if err := servers.Encode([]map[string]string{{"url": url}}); err != nil {
+		return nil, err
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "servers" {
+			root.Content[i+1] = &servers
+			return &doc, nil
+		}
+	}
+
+	var key yaml.Node
+	key.SetString("servers")
+	root.Content = append(root.Content, &key, &servers)
+
+	return &doc, nil
+}
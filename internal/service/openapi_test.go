@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestServerURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		tls     bool
+		want    string
+	}{
+		{name: "all interfaces", address: ":8080", want: "http://localhost:8080/v1"},
+		{name: "unspecified IPv4", address: "0.0.0.0:8080", want: "http://localhost:8080/v1"},
+		{name: "unspecified IPv6", address: "[::]:8080", want: "http://localhost:8080/v1"},
+		{name: "host", address: "api.example:8080", want: "http://api.example:8080/v1"},
+		{name: "IPv6", address: "[::1]:8443", tls: true, want: "https://[::1]:8443/v1"},
+		{name: "tls", address: "127.0.0.1:8443", tls: true, want: "https://127.0.0.1:8443/v1"},
+		{name: "default port", address: "api.example", want: "http://api.example/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverURL(tt.address, tt.tls); got != tt.want {
+				t.Errorf("serverURL(%q, %t) = %s, want %s", tt.address, tt.tls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAPIHandlersIgnoreHost(t *testing.T) {
+	const url = "https://api.example:8443/v1"
+	o := openAPI{serverURL: url}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		decode  func([]byte, any) error
+	}{
+		{name: "yaml", handler: o.yamlHandler, decode: yaml.Unmarshal},
+		{name: "json", handler: o.jsonHandler, decode: json.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/openapi", nil)
+			r.Host = "attacker.example"
+
+			rec := httptest.NewRecorder()
+			tt.handler(rec, r)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var doc struct {
+				Servers []struct {
+					URL string `yaml:"url" json:"url"`
+				} `yaml:"servers" json:"servers"`
+			}
+			if err := tt.decode(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("decode document: %s", err)
+			}
+
+			if len(doc.Servers) != 1 || doc.Servers[0].URL != url {
+				t.Errorf("servers = %+v, want only %s", doc.Servers, url)
+			}
+		})
+	}
+}
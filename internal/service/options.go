@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
+)
+
+const (
+	defaultAddress           = ":8080"
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 10 * time.Second
+	defaultWriteTimeout      = 15 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+	defaultMaxBodyBytes      = 1 << 20
+	defaultHandlerTimeout    = 10 * time.Second
+)
+
+type Option func(*options)
+
+type options struct {
+	address           string
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	maxBodyBytes      int64
+	handlerTimeout    time.Duration
+	routeTimeouts     map[string]time.Duration
+	accessLog         AccessLogOptions
+	rateLimit         RateLimitOptions
+	concurrencyLimit  ConcurrencyLimitOptions
+	apiExplorer       bool
+	tls               tlsconfig.Options
+}
+
+func defaultOptions() options {
+	return options{
+		address:           defaultAddress,
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		readTimeout:       defaultReadTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		idleTimeout:       defaultIdleTimeout,
+		maxHeaderBytes:    defaultMaxHeaderBytes,
+		maxBodyBytes:      defaultMaxBodyBytes,
+		handlerTimeout:    defaultHandlerTimeout,
+		routeTimeouts:     map[string]time.Duration{},
+		accessLog:         DefaultAccessLogOptions(),
+		rateLimit:         DefaultRateLimitOptions(),
+		concurrencyLimit:  DefaultConcurrencyLimitOptions(),
+	}
+}
+
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readHeaderTimeout = timeout
+	}
+}
+
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = timeout
+	}
+}
+
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = timeout
+	}
+}
+
+func WithMaxHeaderBytes(n int) Option {
+	return func(o *options) {
+		o.maxHeaderBytes = n
+	}
+}
+
+// WithMaxBodyBytes limits the size of request bodies. Zero disables the limit.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = n
+	}
+}
+
+// WithHandlerTimeout sets the deadline of the api handlers. Zero disables it.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.handlerTimeout = timeout
+	}
+}
+
+// WithRouteTimeout overrides the handler deadline of a single route, named by
+// its chi route pattern, such as /v1/info/{id}.
+func WithRouteTimeout(route string, timeout time.Duration) Option {
+	return func(o *options) {
+		o.routeTimeouts[route] = timeout
+	}
+}
+
+func WithAccessLog(opts AccessLogOptions) Option {
+	return func(o *options) {
+		o.accessLog = opts
+	}
+}
+
+// WithRateLimit limits the rate of requests per client and globally.
+func WithRateLimit(opts RateLimitOptions) Option {
+	return func(o *options) {
+		o.rateLimit = opts
+	}
+}
+
+// WithConcurrencyLimit limits the number of requests served at once.
+func WithConcurrencyLimit(opts ConcurrencyLimitOptions) Option {
+	return func(o *options) {
+		o.concurrencyLimit = opts
+	}
+}
+
+// WithAPIExplorer serves a page listing the API operations and sending
+// requests to them at /v1/explorer.
+func WithAPIExplorer(enabled bool) Option {
+	return func(o *options) {
+		o.apiExplorer = enabled
+	}
+}
+
+// WithTLS serves the API over TLS. With a client CA file, clients must
+// present a certificate signed by it.
+func WithTLS(opts tlsconfig.Options) Option {
+	return func(o *options) {
+		o.tls = opts
+	}
+}
+
+func (o options) validate() error {
+	var errs []error
+
+	if o.address == "" {
+		errs = append(errs, errors.New("address must not be empty"))
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read header timeout", o.readHeaderTimeout},
+		{"read timeout", o.readTimeout},
+		{"write timeout", o.writeTimeout},
+		{"idle timeout", o.idleTimeout},
+		{"handler timeout", o.handlerTimeout},
+	}
+
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.name, d.value))
+		}
+	}
+
+	if o.maxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max header bytes must not be negative, got %d", o.maxHeaderBytes))
+	}
+
+	if o.maxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("max body bytes must not be negative, got %d", o.maxBodyBytes))
+	}
+
+	// The timeout response has to be written before the connection deadline.
+	if o.writeTimeout > 0 && o.handlerTimeout >= o.writeTimeout {
+		errs = append(errs, fmt.Errorf("handler timeout %s must be shorter than write timeout %s", o.handlerTimeout, o.writeTimeout))
+	}
+
+	for route, timeout := range o.routeTimeouts {
+		if timeout < 0 {
+			errs = append(errs, fmt.Errorf("timeout of route %s must not be negative, got %s", route, timeout))
+		}
+
+		if o.writeTimeout > 0 && timeout >= o.writeTimeout {
+			errs = append(errs, fmt.Errorf("timeout %s of route %s must be shorter than write timeout %s", timeout, route, o.writeTimeout))
+		}
+	}
+
+	if err := o.tls.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("tls: %w", err))
+	}
+
+	if err := o.accessLog.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("access log: %w", err))
+	}
+
+	if err := o.rateLimit.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("rate limit: %w", err))
+	}
+
+	if err := o.concurrencyLimit.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("concurrency limit: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
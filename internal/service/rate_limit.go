@@ -0,0 +1,271 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"golang.org/x/time/rate"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+
+	// rateLimitClientIdle is how long the bucket of a client is kept after
+	// its last request.
+	rateLimitClientIdle = 5 * time.Minute
+
+	defaultRateLimitMaxClients = 10000
+)
+
+// RateLimitKey selects how clients are told apart by the rate limiter.
+type RateLimitKey string
+
+const (
+	// RateLimitByIP limits every client IP address separately.
+	RateLimitByIP RateLimitKey = "ip"
+	// RateLimitByAPIKey limits every known API key, sent in the X-API-Key
+	// header, separately. Requests without a known one are limited by IP
+	// address, so that clients can't get a fresh bucket by sending a new key.
+	RateLimitByAPIKey RateLimitKey = "api-key"
+)
+
+// RateLimitOptions configures the token buckets limiting the request rate of
+// each client and of all of them together. Rates are in requests per second,
+// and bursts are the number of requests that can be made at once. A zero rate
+// disables the corresponding limit.
+//
+// APIKeys are the keys told apart with RateLimitByAPIKey. At most MaxClients
+// client buckets are kept, the least recently seen client losing its bucket
+// to a new one.
+type RateLimitOptions struct {
+	Key         RateLimitKey
+	APIKeys     []string
+	ClientRate  float64
+	ClientBurst int
+	MaxClients  int
+	GlobalRate  float64
+	GlobalBurst int
+}
+
+func DefaultRateLimitOptions() RateLimitOptions {
+	return RateLimitOptions{
+		Key:        RateLimitByIP,
+		MaxClients: defaultRateLimitMaxClients,
+	}
+}
+
+func (o RateLimitOptions) Validate() error {
+	if o.Key != RateLimitByIP && o.Key != RateLimitByAPIKey {
+		return fmt.Errorf("key must be %q or %q, got %q", RateLimitByIP, RateLimitByAPIKey, o.Key)
+	}
+
+	if o.ClientRate < 0 || o.GlobalRate < 0 {
+		return fmt.Errorf("rates must not be negative, got %v per client and %v globally", o.ClientRate, o.GlobalRate)
+	}
+
+	if o.ClientRate > 0 && o.ClientBurst < 1 {
+		return fmt.Errorf("client burst must be at least 1, got %d", o.ClientBurst)
+	}
+
+	if o.GlobalRate > 0 && o.GlobalBurst < 1 {
+		return fmt.Errorf("global burst must be at least 1, got %d", o.GlobalBurst)
+	}
+
+	if o.ClientRate > 0 && o.MaxClients < 1 {
+		return fmt.Errorf("max clients must be at least 1, got %d", o.MaxClients)
+	}
+
+	if o.Key == RateLimitByAPIKey && len(o.APIKeys) == 0 {
+		return errors.New("api keys must be set to limit by api key")
+	}
+
+	if slices.Contains(o.APIKeys, "") {
+		return errors.New("api keys must not be empty")
+	}
+
+	return nil
+}
+
+func (o RateLimitOptions) equal(p RateLimitOptions) bool {
+	return o.Key == p.Key &&
+		slices.Equal(o.APIKeys, p.APIKeys) &&
+		o.ClientRate == p.ClientRate &&
+		o.ClientBurst == p.ClientBurst &&
+		o.MaxClients == p.MaxClients &&
+		o.GlobalRate == p.GlobalRate &&
+		o.GlobalBurst == p.GlobalBurst
+}
+
+// rateLimiter rejects the requests exceeding the rate limits. Its options can
+// be replaced while the server is running, which resets the buckets.
+type rateLimiter struct {
+	metrics *monitoring.Registry
+	state   atomic.Pointer[rateLimitState]
+}
+
+type rateLimitState struct {
+	opts    RateLimitOptions
+	apiKeys map[[sha256.Size]byte]struct{}
+	global  *rate.Limiter
+
+	m       sync.Mutex
+	clients map[string]*list.Element
+	// recent orders the clients from the most to the least recently seen.
+	recent *list.List
+}
+
+type rateLimitClient struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(metrics *monitoring.Registry, opts RateLimitOptions) *rateLimiter {
+	l := &rateLimiter{
+		metrics: metrics,
+	}
+	l.Configure(opts)
+
+	return l
+}
+
+func (l *rateLimiter) Configure(opts RateLimitOptions) {
+	if current := l.state.Load(); current != nil && current.opts.equal(opts) {
+		return
+	}
+
+	state := &rateLimitState{
+		opts:    opts,
+		apiKeys: make(map[[sha256.Size]byte]struct{}, len(opts.APIKeys)),
+		clients: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+
+	for _, key := range opts.APIKeys {
+		state.apiKeys[sha256.Sum256([]byte(key))] = struct{}{}
+	}
+
+	if opts.GlobalRate > 0 {
+		state.global = rate.NewLimiter(rate.Limit(opts.GlobalRate), opts.GlobalBurst)
+	}
+
+	l.state.Store(state)
+}
+
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, ok := l.state.Load().reserve(r, time.Now())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		l.metrics.ObserveRateLimited(routePattern(r))
+
+		seconds := int(math.Ceil(delay.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		_ = renderError(w, r, http.StatusTooManyRequests, "rate-limited")
+	})
+}
+
+// reserve takes a token from the bucket of the client and from the global
+// one. When either is empty, no token is taken and the time until the
+// request would be allowed is returned.
+func (s *rateLimitState) reserve(r *http.Request, now time.Time) (time.Duration, bool) {
+	var reservations []*rate.Reservation
+
+	if s.opts.ClientRate > 0 {
+		reservations = append(reservations, s.client(r, now).ReserveN(now, 1))
+	}
+
+	if s.global != nil {
+		reservations = append(reservations, s.global.ReserveN(now, 1))
+	}
+
+	var delay time.Duration
+	for _, res := range reservations {
+		delay = max(delay, res.DelayFrom(now))
+	}
+
+	if delay == 0 {
+		return 0, true
+	}
+
+	for _, res := range reservations {
+		res.CancelAt(now)
+	}
+
+	return delay, false
+}
+
+func (s *rateLimitState) client(r *http.Request, now time.Time) *rate.Limiter {
+	key := s.clientKey(r)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for e := s.recent.Back(); e != nil && now.Sub(e.Value.(*rateLimitClient).lastSeen) >= rateLimitClientIdle; e = s.recent.Back() {
+		s.remove(e)
+	}
+
+	if e, ok := s.clients[key]; ok {
+		c := e.Value.(*rateLimitClient)
+		c.lastSeen = now
+		s.recent.MoveToFront(e)
+
+		return c.limiter
+	}
+
+	if s.recent.Len() >= s.opts.MaxClients {
+		s.remove(s.recent.Back())
+	}
+
+	c := &rateLimitClient{
+		key:      key,
+		limiter:  rate.NewLimiter(rate.Limit(s.opts.ClientRate), s.opts.ClientBurst),
+		lastSeen: now,
+	}
+	s.clients[key] = s.recent.PushFront(c)
+
+	return c.limiter
+}
+
+func (s *rateLimitState) remove(e *list.Element) {
+	s.recent.Remove(e)
+	delete(s.clients, e.Value.(*rateLimitClient).key)
+}
+
+// clientKey returns the key of the bucket of the client. API keys are only
+// trusted when known.
+func (s *rateLimitState) clientKey(r *http.Request) string {
+	if s.opts.Key == RateLimitByAPIKey {
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			if _, ok := s.apiKeys[sum]; ok {
+				return "api-key:" + string(sum[:])
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
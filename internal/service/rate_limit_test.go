@@ -0,0 +1,170 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+)
+
+type rateLimitRequest struct {
+	remoteAddr string
+	apiKey     string
+}
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     RateLimitOptions
+		requests []rateLimitRequest
+		want     []int
+	}{
+		{
+			name:     "disabled",
+			opts:     DefaultRateLimitOptions(),
+			requests: []rateLimitRequest{{remoteAddr: "10.0.0.1:1"}, {remoteAddr: "10.0.0.1:1"}},
+			want:     []int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name: "per client ip",
+			opts: RateLimitOptions{Key: RateLimitByIP, ClientRate: 1, ClientBurst: 1, MaxClients: 10},
+			requests: []rateLimitRequest{
+				{remoteAddr: "10.0.0.1:1"},
+				{remoteAddr: "10.0.0.1:2"},
+				{remoteAddr: "10.0.0.2:1"},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name: "global",
+			opts: RateLimitOptions{Key: RateLimitByIP, GlobalRate: 1, GlobalBurst: 2},
+			requests: []rateLimitRequest{
+				{remoteAddr: "10.0.0.1:1"},
+				{remoteAddr: "10.0.0.2:1"},
+				{remoteAddr: "10.0.0.3:1"},
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "known api keys are limited separately",
+			opts: RateLimitOptions{Key: RateLimitByAPIKey, APIKeys: []string{"a", "b"}, ClientRate: 1, ClientBurst: 1, MaxClients: 10},
+			requests: []rateLimitRequest{
+				{remoteAddr: "10.0.0.1:1", apiKey: "a"},
+				{remoteAddr: "10.0.0.1:1", apiKey: "b"},
+				{remoteAddr: "10.0.0.1:1", apiKey: "a"},
+			},
+			want: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "unknown api keys are limited by ip",
+			opts: RateLimitOptions{Key: RateLimitByAPIKey, APIKeys: []string{"a"}, ClientRate: 1, ClientBurst: 1, MaxClients: 10},
+			requests: []rateLimitRequest{
+				{remoteAddr: "10.0.0.1:1", apiKey: "x"},
+				{remoteAddr: "10.0.0.1:1", apiKey: "y"},
+				{remoteAddr: "10.0.0.1:1"},
+				{remoteAddr: "10.0.0.1:1", apiKey: "a"},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name: "least recently seen client is forgotten",
+			opts: RateLimitOptions{Key: RateLimitByIP, ClientRate: 1, ClientBurst: 1, MaxClients: 2},
+			requests: []rateLimitRequest{
+				{remoteAddr: "10.0.0.1:1"},
+				{remoteAddr: "10.0.0.2:1"},
+				{remoteAddr: "10.0.0.2:1"},
+				{remoteAddr: "10.0.0.3:1"},
+				{remoteAddr: "10.0.0.2:1"},
+				{remoteAddr: "10.0.0.1:1"},
+			},
+			want: []int{
+				http.StatusOK, http.StatusOK, http.StatusTooManyRequests,
+				http.StatusOK, http.StatusTooManyRequests, http.StatusOK,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); err != nil {
+				t.Fatalf("Validate() error = %s", err)
+			}
+
+			l := newRateLimiter(monitoring.NewRegistry(), tt.opts)
+			handler := l.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				r.RemoteAddr = req.remoteAddr
+				if req.apiKey != "" {
+					r.Header.Set(apiKeyHeader, req.apiKey)
+				}
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, r)
+
+				if rec.Code != tt.want[i] {
+					t.Errorf("request %d: status = %d, want %d", i, rec.Code, tt.want[i])
+				}
+
+				if rec.Code == http.StatusTooManyRequests {
+					if seconds, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || seconds < 1 {
+						t.Errorf("request %d: Retry-After = %q, want a positive number of seconds", i, rec.Header().Get("Retry-After"))
+					}
+				}
+			}
+
+			if n := l.state.Load().recent.Len(); n > max(tt.opts.MaxClients, 0) {
+				t.Errorf("%d clients tracked, want at most %d", n, tt.opts.MaxClients)
+			}
+		})
+	}
+}
+
+func TestRateLimiterForgetsIdleClients(t *testing.T) {
+	l := newRateLimiter(monitoring.NewRegistry(), RateLimitOptions{Key: RateLimitByIP, ClientRate: 1, ClientBurst: 1, MaxClients: 10})
+	state := l.state.Load()
+
+	now := time.Now()
+	for _, addr := range []string{"10.0.0.1:1", "10.0.0.2:1"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = addr
+		state.client(r, now)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.3:1"
+	state.client(r, now.Add(rateLimitClientIdle))
+
+	if n := len(state.clients); n != 1 {
+		t.Errorf("%d clients tracked after the others went idle, want 1", n)
+	}
+}
+
+func TestRateLimitOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    RateLimitOptions
+		wantErr bool
+	}{
+		{name: "default", opts: DefaultRateLimitOptions()},
+		{name: "unknown key", opts: RateLimitOptions{Key: "cookie"}, wantErr: true},
+		{name: "negative rate", opts: RateLimitOptions{Key: RateLimitByIP, GlobalRate: -1}, wantErr: true},
+		{name: "client rate without burst", opts: RateLimitOptions{Key: RateLimitByIP, ClientRate: 1, MaxClients: 1}, wantErr: true},
+		{name: "client rate without max clients", opts: RateLimitOptions{Key: RateLimitByIP, ClientRate: 1, ClientBurst: 1}, wantErr: true},
+		{name: "api key without keys", opts: RateLimitOptions{Key: RateLimitByAPIKey}, wantErr: true},
+		{name: "empty api key", opts: RateLimitOptions{Key: RateLimitByAPIKey, APIKeys: []string{""}}, wantErr: true},
+		{name: "api keys", opts: RateLimitOptions{Key: RateLimitByAPIKey, APIKeys: []string{"a"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+)
+
+// recoverer recovers from panics in the handlers, logs them with the stack
+// and renders an internal server error if nothing was written yet.
+func recoverer(metrics *monitoring.Registry) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := wrapResponseWriter(w)
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				// ErrAbortHandler is the sanctioned way of aborting a response.
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				stack := debug.Stack()
+				if hp, ok := p.(*handlerPanic); ok {
+					p, stack = hp.value, hp.stack
+				}
+
+				metrics.ObservePanic(routePattern(r))
+				logger(r).
+					WithField("panic", fmt.Sprint(p)).
+					WithField("stack", string(stack)).
+					Error("Recovered from panic")
+
+				if !rw.wroteHeader {
+					_ = renderError(rw, r, http.StatusInternalServerError, "internal-server-error")
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+func TestRecoverer(t *testing.T) {
+	tests := []struct {
+		name       string
+		route      string
+		handler    http.HandlerFunc
+		timeouts   bool
+		wantStatus int
+		wantBody   string
+		wantFrame  string
+	}{
+		{
+			name:       "panic before writing",
+			route:      "/panic",
+			handler:    panickingHandler,
+			wantStatus: http.StatusInternalServerError,
+			wantFrame:  "panickingHandler",
+		},
+		{
+			name:       "panic under a handler timeout",
+			route:      "/timed/{id}",
+			handler:    panickingHandler,
+			timeouts:   true,
+			wantStatus: http.StatusInternalServerError,
+			wantFrame:  "panickingHandler",
+		},
+		{
+			name:       "panic after writing",
+			route:      "/partial",
+			handler:    partialHandler,
+			wantStatus: http.StatusOK,
+			wantBody:   "partial",
+			wantFrame:  "partialHandler",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := monitoring.NewRegistry()
+			log, recorded := newRecordedLogger()
+
+			router := chi.NewRouter()
+			router.Use(logPath(log), requestIDMiddleware, recoverer(metrics))
+			if tt.timeouts {
+				router.With(handlerTimeouts{timeout: time.Hour}.middleware).Get(tt.route, tt.handler)
+			} else {
+				router.Get(tt.route, tt.handler)
+			}
+
+			path := strings.ReplaceAll(tt.route, "{id}", "1")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantBody != "" {
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+				}
+			} else {
+				var body api.Error
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decode error body %q: %s", rec.Body.String(), err)
+				}
+				if body.Error != "internal-server-error" {
+					t.Errorf("error = %q, want internal-server-error", body.Error)
+				}
+				if id := rec.Header().Get(requestIDHeader); body.RequestId == nil || *body.RequestId != id {
+					t.Errorf("error body request_id = %v, want %q", body.RequestId, id)
+				}
+			}
+
+			if n := panics(t, metrics, tt.route); n != 1 {
+				t.Errorf("http_panics_total{route=%q} = %v, want 1", tt.route, n)
+			}
+
+			if len(recorded.entries) != 1 {
+				t.Fatalf("%d entries logged, want 1", len(recorded.entries))
+			}
+			stack, _ := recorded.entries[0].Data["stack"].(string)
+			if !strings.Contains(stack, tt.wantFrame) {
+				t.Errorf("logged stack does not contain the handler:\n%s", stack)
+			}
+		})
+	}
+}
+
+func partialHandler(w http.ResponseWriter, _ *http.Request) {
+	_, _ = io.WriteString(w, "partial")
+	panic("boom")
+}
+
+func TestRecovererRepanicsAbortHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts bool
+	}{
+		{name: "direct"},
+		{name: "under a handler timeout", timeouts: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := monitoring.NewRegistry()
+
+			var handler http.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			})
+			if tt.timeouts {
+				handler = handlerTimeouts{timeout: time.Hour}.middleware(handler)
+			}
+			handler = recoverer(metrics)(handler)
+
+			rec := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if err, ok := recover().(error); !ok || !errors.Is(err, http.ErrAbortHandler) {
+						t.Errorf("recovered %v, want %v", err, http.ErrAbortHandler)
+					}
+				}()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			}()
+
+			if rec.Body.Len() != 0 {
+				t.Errorf("body = %q, want nothing written", rec.Body.String())
+			}
+			if n := panics(t, metrics, ""); n != 0 {
+				t.Errorf("http_panics_total = %v, want 0", n)
+			}
+		})
+	}
+}
+
+// panics returns the panics counted for route, or for every route when it
+// is empty.
+func panics(t *testing.T, metrics *monitoring.Registry, route string) float64 {
+	t.Helper()
+
+	families, err := metrics.Gatherer().Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %s", err)
+	}
+
+	var n float64
+	for _, f := range families {
+		if f.GetName() != "http_panics_total" {
+			continue
+		}
+
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "route" && (route == "" || l.GetValue() == route) {
+					n += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 64
+)
+
+type ctxRequestIDKey struct{}
+
+// requestIDMiddleware accepts the request ID sent by the client or generates
+// one, and attaches it to the request context, the request logger and the
+// response headers.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), ctxRequestIDKey{}, id)
+		r = r.WithContext(ctx)
+		r = newRequestWithLogger(r, logger(r).WithField("request_id", id))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(ctxRequestIDKey{}).(string)
+
+	return id
+}
+
+// validRequestID reports whether a client provided ID is safe to log, echo
+// and use as an exemplar label.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/pkg/api"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantEcho bool
+	}{
+		{name: "valid", incoming: "req-42", wantEcho: true},
+		{name: "longest", incoming: strings.Repeat("a", maxRequestIDLength), wantEcho: true},
+		{name: "missing"},
+		{name: "too long", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", incoming: "req 42"},
+		{name: "control character", incoming: "req\x7f42"},
+		{name: "non ascii", incoming: "réq"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields logrus.Fields
+			handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fields = logger(r).Data
+				_ = renderError(w, r, http.StatusBadRequest, "invalid-argument")
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set(requestIDHeader, tt.incoming)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			id := rec.Header().Get(requestIDHeader)
+			if tt.wantEcho {
+				if id != tt.incoming {
+					t.Errorf("%s = %q, want the incoming %q", requestIDHeader, id, tt.incoming)
+				}
+			} else if _, err := uuid.Parse(id); err != nil {
+				t.Errorf("%s = %q, want a generated UUID", requestIDHeader, id)
+			}
+
+			var body api.Error
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode error body: %s", err)
+			}
+			if body.RequestId == nil || *body.RequestId != id {
+				t.Errorf("error body request_id = %v, want %q", body.RequestId, id)
+			}
+
+			if fields["request_id"] != id {
+				t.Errorf("logger request_id = %v, want %q", fields["request_id"], id)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddlewareGeneratesUniqueIDs(t *testing.T) {
+	handler := requestIDMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		id := rec.Header().Get(requestIDHeader)
+		if seen[id] {
+			t.Fatalf("request ID %q generated twice", id)
+		}
+		seen[id] = true
+	}
+}
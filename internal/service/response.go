@@ -0,0 +1,68 @@
+package service
+
+import (
+	"net/http"
+)
+
+// responseWriter records the status code and the number of bytes written
+// through the wrapped http.ResponseWriter.
+type responseWriter struct {
+	http.ResponseWriter
+	status       int
+	bytesWritten int64
+	wroteHeader  bool
+}
+
+// wrapResponseWriter wraps w, reusing it when it is already wrapped so that
+// every middleware observes the same response.
+func wrapResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.status = statusCode
+		w.wroteHeader = true
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += int64(n)
+
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status code of the response, http.StatusOK when the
+// handler did not write anything.
+func (w *responseWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+func (w *responseWriter) BytesWritten() int64 {
+	return w.bytesWritten
+}
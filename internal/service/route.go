@@ -0,0 +1,41 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// unmatchedRoute is the route of requests answered by the not found and
+// method not allowed handlers.
+const unmatchedRoute = "unmatched"
+
+// routePattern returns the chi route pattern the request is, or will be,
+// routed to. Router level middlewares run before routing, so the pattern is
+// resolved against the router ahead of time.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return unmatchedRoute
+	}
+
+	if pattern := rctx.RoutePattern(); pattern != "" {
+		return pattern
+	}
+
+	if rctx.Routes == nil {
+		return unmatchedRoute
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return unmatchedRoute
+	}
+
+	return tctx.RoutePattern()
+}
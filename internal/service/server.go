@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -10,62 +12,101 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/internal/monitoring"
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
 	"github.com/theskch/prometheus-issue/pkg/api"
 )
 
 const (
-	defaultReadTimeout     = 10 * time.Second
 	defaultShutdownTimeout = 5 * time.Second
-)
-
-var (
-	notFoundJSONResponseBody = func() []byte {
-		b, _ := json.Marshal(&api.Error{
-			Error: "not-found",
-		})
-
-		return b
-	}()
-
-	methodNotAllowedResponseBody = func() []byte {
-		b, _ := json.Marshal(&api.Error{
-			Error: "method-not-allowed",
-		})
 
-		return b
-	}()
+	baseURL = "/v1"
 )
 
 type Server struct {
-	server  *http.Server
-	serving int32
-	m       sync.Mutex
+	server    *http.Server
+	accessLog *accessLogger
+	rateLimit *rateLimiter
+	serving   int32
+	inFlight  int64
+	m         sync.Mutex
 }
 
-func NewServer(address string) *Server {
+func NewServer(metrics *monitoring.Registry, opts ...Option) (*Server, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	err := o.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid service server options: %w", err)
+	}
+
+	accessLog := newAccessLogger(o.accessLog)
+	rateLimit := newRateLimiter(metrics, o.rateLimit)
+	s := &Server{
+		accessLog: accessLog,
+		rateLimit: rateLimit,
+	}
+
 	r := chi.NewRouter()
 
 	r.NotFound(notFoundHandler)
 	r.MethodNotAllowed(methodNotAllowedHandler)
 
 	logger := logrus.NewEntry(logrus.StandardLogger())
+	r.Use(s.countInFlight)
+	r.Use(instrument(metrics))
 	r.Use(logPath(logger))
+	r.Use(requestIDMiddleware)
+	r.Use(accessLog.middleware)
+	r.Use(rateLimit.middleware)
+	r.Use(newConcurrencyLimiter(metrics, o.concurrencyLimit).middleware)
+	r.Use(recoverer(metrics))
+	r.Use(limitBody(o.maxBodyBytes))
+
+	timeouts := handlerTimeouts{
+		timeout: o.handlerTimeout,
+		routes:  o.routeTimeouts,
+	}
 
 	serverOptions := api.ChiServerOptions{
-		BaseURL:          "/v1",
+		BaseURL:          baseURL,
 		BaseRouter:       r,
+		Middlewares:      []api.MiddlewareFunc{timeouts.middleware},
 		ErrorHandlerFunc: errorHandler,
 	}
 
-	api.HandlerWithOptions(app{}, serverOptions)
+	api.HandlerWithOptions(app{metrics: metrics}, serverOptions)
+
+	openAPI := openAPI{serverURL: serverURL(o.address, o.tls.Enabled())}
+	r.Get(baseURL+"/openapi.yaml", openAPI.yamlHandler)
+	r.Get(baseURL+"/openapi.json", openAPI.jsonHandler)
+	if o.apiExplorer {
+		r.Get(baseURL+"/explorer", explorerHandler)
+	}
+
+	s.server = &http.Server{
+		Addr:              o.address,
+		Handler:           r,
+		ReadHeaderTimeout: o.readHeaderTimeout,
+		ReadTimeout:       o.readTimeout,
+		WriteTimeout:      o.writeTimeout,
+		IdleTimeout:       o.idleTimeout,
+		MaxHeaderBytes:    o.maxHeaderBytes,
+	}
+
+	if o.tls.Enabled() {
+		o.tls.ClientAuth = tls.RequireAndVerifyClientCert
 
-	return &Server{
-		server: &http.Server{
-			Addr:        address,
-			Handler:     r,
-			ReadTimeout: defaultReadTimeout,
-		},
+		s.server.TLSConfig, err = tlsconfig.NewServerConfig(o.tls)
+		if err != nil {
+			return nil, fmt.Errorf("configure service server tls: %w", err)
+		}
 	}
+
+	return s, nil
 }
 
 func (s *Server) ListenAndServe() error {
@@ -77,6 +118,10 @@ func (s *Server) ListenAndServe() error {
 		atomic.StoreInt32(&s.serving, 0)
 	}()
 
+	if s.server.TLSConfig != nil {
+		return s.server.ListenAndServeTLS("", "")
+	}
+
 	return s.server.ListenAndServe()
 }
 
@@ -84,6 +129,12 @@ func (s *Server) GracefulStop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
 	defer cancel()
 
+	return s.Shutdown(ctx)
+}
+
+// Shutdown stops the server once the requests in flight are served, or
+// when ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
@@ -91,20 +142,59 @@ func (s *Server) Serving() bool {
 	return atomic.LoadInt32(&s.serving) == 1
 }
 
+// ConfigureAccessLog replaces the access log options of the running server.
+func (s *Server) ConfigureAccessLog(opts AccessLogOptions) {
+	s.accessLog.Configure(opts)
+}
+
+// ConfigureRateLimit replaces the rate limits of the running server. The
+// buckets are reset when the limits change.
+func (s *Server) ConfigureRateLimit(opts RateLimitOptions) {
+	s.rateLimit.Configure(opts)
+}
+
+// InFlight returns the number of requests being served.
+func (s *Server) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+func (s *Server) countInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	_ = renderRawJSON(w, http.StatusNotFound, notFoundJSONResponseBody)
+	_ = renderError(w, r, http.StatusNotFound, "not-found")
 }
 
 func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
-	_ = renderRawJSON(w, http.StatusMethodNotAllowed, methodNotAllowedResponseBody)
+	_ = renderError(w, r, http.StatusMethodNotAllowed, "method-not-allowed")
 }
 
 func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	errorResponse, _ := json.Marshal(&api.Error{
-		Error: err.Error(),
-	})
+	_ = renderError(w, r, http.StatusBadRequest, err.Error())
+}
+
+// renderError renders an api.Error carrying the ID of the request.
+func renderError(w http.ResponseWriter, r *http.Request, statusCode int, message string) error {
+	apiErr := api.Error{
+		Error: message,
+	}
+
+	if id := requestID(r); id != "" {
+		apiErr.RequestId = &id
+	}
+
+	payload, err := json.Marshal(&apiErr)
+	if err != nil {
+		return err
+	}
 
-	_ = renderRawJSON(w, http.StatusBadRequest, errorResponse)
+	return renderRawJSON(w, statusCode, payload)
 }
 
 func renderRawJSON(w http.ResponseWriter, statusCode int, payload []byte) (err error) {
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// handlerTimeouts bounds the time the api handlers have to respond. It runs
+// after routing, so that timeouts can be set per route pattern.
+type handlerTimeouts struct {
+	timeout time.Duration
+	routes  map[string]time.Duration
+}
+
+func (t handlerTimeouts) forRoute(route string) time.Duration {
+	if timeout, ok := t.routes[route]; ok {
+		return timeout
+	}
+
+	return t.timeout
+}
+
+// middleware runs the handler with a deadline, buffering its response. When
+// the deadline is exceeded first, a 503 api.Error is rendered instead and
+// whatever the handler writes afterwards is discarded.
+func (t handlerTimeouts) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := t.forRoute(routePattern(r))
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		r = r.WithContext(ctx)
+		tw := &timeoutWriter{header: make(http.Header)}
+
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+						panicked <- p
+						return
+					}
+
+					// The stack of the handler is lost once the panic is
+					// raised again on the serving goroutine.
+					panicked <- &handlerPanic{value: p, stack: debug.Stack()}
+				}
+			}()
+
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			// Panic on the serving goroutine, for the recoverer to handle it.
+			panic(p)
+		case <-done:
+			tw.flush(w)
+		case <-ctx.Done():
+			// A handler finishing right at the deadline still gets its
+			// response sent.
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.flush(w)
+				return
+			default:
+			}
+
+			tw.abandon()
+
+			// The client went away, there is nobody to tell about it.
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return
+			}
+
+			logger(r).WithField("timeout", timeout).Warning("Handler deadline exceeded")
+			_ = renderError(w, r, http.StatusServiceUnavailable, "timeout")
+		}
+	})
+}
+
+// handlerPanic carries a panic recovered in the goroutine of a handler
+// running under a deadline, along with the stack of that goroutine.
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// timeoutWriter buffers the response of a handler running under a deadline.
+type timeoutWriter struct {
+	m           sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	abandoned   bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.abandoned || w.wroteHeader {
+		return
+	}
+
+	w.status = statusCode
+	w.wroteHeader = true
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+		w.wroteHeader = true
+	}
+
+	return w.buf.Write(b)
+}
+
+func (w *timeoutWriter) abandon() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.abandoned = true
+}
+
+func (w *timeoutWriter) flush(dst http.ResponseWriter) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	for k, v := range w.header {
+		dst.Header()[k] = v
+	}
+
+	if !w.wroteHeader {
+		w.status = http.StatusOK
+	}
+
+	dst.WriteHeader(w.status)
+	_, _ = dst.Write(w.buf.Bytes())
+}
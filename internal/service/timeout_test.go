@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandlerTimeoutsMiddleware(t *testing.T) {
+	fast := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "fast")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, "done")
+	}
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		// Outlive the deadline, rather than finish right at it.
+		<-r.Context().Done()
+		time.Sleep(50 * time.Millisecond)
+		_, _ = io.WriteString(w, "late")
+	}
+
+	tests := []struct {
+		name       string
+		timeouts   handlerTimeouts
+		route      string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name:       "response within deadline",
+			timeouts:   handlerTimeouts{timeout: time.Second},
+			route:      "/fast",
+			handler:    fast,
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+			wantHeader: "fast",
+		},
+		{
+			name:       "deadline exceeded",
+			timeouts:   handlerTimeouts{timeout: 10 * time.Millisecond},
+			route:      "/slow",
+			handler:    slow,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"error":"timeout"`,
+		},
+		{
+			name:       "route timeout overrides default",
+			timeouts:   handlerTimeouts{timeout: time.Hour, routes: map[string]time.Duration{"/slow": 10 * time.Millisecond}},
+			route:      "/slow",
+			handler:    slow,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"error":"timeout"`,
+		},
+		{
+			name:       "zero route timeout disables deadline",
+			timeouts:   handlerTimeouts{timeout: 10 * time.Millisecond, routes: map[string]time.Duration{"/fast": 0}},
+			route:      "/fast",
+			handler:    fast,
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+			wantHeader: "fast",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := chi.NewRouter()
+			router.With(tt.timeouts.middleware).Get(tt.route, tt.handler)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.route, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("X-Handler"); got != tt.wantHeader {
+				t.Errorf("X-Handler = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestHandlerTimeoutsMiddlewareClientGone(t *testing.T) {
+	handler := handlerTimeouts{timeout: time.Hour}.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want nothing written for a gone client", rec.Body.String())
+	}
+}
+
+func TestHandlerTimeoutsMiddlewarePanic(t *testing.T) {
+	handler := handlerTimeouts{timeout: time.Hour}.middleware(http.HandlerFunc(panickingHandler))
+
+	defer func() {
+		hp, ok := recover().(*handlerPanic)
+		if !ok {
+			t.Fatal("middleware did not panic with a handlerPanic")
+		}
+		if hp.value != "boom" {
+			t.Errorf("panic value = %v, want boom", hp.value)
+		}
+		if !strings.Contains(string(hp.stack), "panickingHandler") {
+			t.Errorf("stack does not contain the handler:\n%s", hp.stack)
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func panickingHandler(http.ResponseWriter, *http.Request) {
+	panic("boom")
+}
+
+func TestTimeoutWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(*timeoutWriter)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "implicit status",
+			write:      func(w *timeoutWriter) { _, _ = w.Write([]byte("body")) },
+			wantStatus: http.StatusOK,
+			wantBody:   "body",
+		},
+		{
+			name:       "nothing written",
+			write:      func(*timeoutWriter) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "first status wins",
+			write: func(w *timeoutWriter) {
+				w.WriteHeader(http.StatusAccepted)
+				w.WriteHeader(http.StatusTeapot)
+			},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "writes after abandon are discarded",
+			write: func(w *timeoutWriter) {
+				_, _ = w.Write([]byte("kept"))
+				w.abandon()
+				if _, err := w.Write([]byte("lost")); !errors.Is(err, http.ErrHandlerTimeout) {
+					t.Errorf("Write after abandon error = %v, want %v", err, http.ErrHandlerTimeout)
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "kept",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &timeoutWriter{header: make(http.Header)}
+			tt.write(w)
+
+			rec := httptest.NewRecorder()
+			w.flush(rec)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReloadInterval = 10 * time.Second
+
+// Options configures the TLS settings of a server. The certificate, the key
+// and the client CA bundle are reloaded when the files change on disk.
+type Options struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile is the CA bundle client certificates are verified
+	// against. When empty, client certificates are not requested.
+	ClientCAFile string
+	// ClientAuth is the client authentication policy used with ClientCAFile.
+	ClientAuth tls.ClientAuthType
+
+	// ReloadInterval is how often, at most, the files are checked for
+	// changes. It defaults to 10 seconds.
+	ReloadInterval time.Duration
+}
+
+// Enabled reports whether TLS is configured.
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+func (o Options) Validate() error {
+	if !o.Enabled() {
+		if o.ClientCAFile != "" {
+			return errors.New("client CA file requires a certificate and a key")
+		}
+
+		return nil
+	}
+
+	if o.CertFile == "" || o.KeyFile == "" {
+		return errors.New("certificate and key files must be set together")
+	}
+
+	if o.ReloadInterval < 0 {
+		return fmt.Errorf("reload interval must not be negative, got %s", o.ReloadInterval)
+	}
+
+	return nil
+}
+
+// NewServerConfig returns the TLS configuration of a server. The files are
+// loaded once up front so that invalid ones are reported immediately.
+func NewServerConfig(opts Options) (*tls.Config, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.ReloadInterval == 0 {
+		opts.ReloadInterval = defaultReloadInterval
+	}
+
+	r := &reloader{
+		opts:   opts,
+		logger: logrus.NewEntry(logrus.StandardLogger()),
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.configForClient,
+		// Only consulted by code inspecting the base configuration, such
+		// as http.Server checking that a certificate is configured.
+		GetCertificate: r.certificate,
+	}, nil
+}
+
+// fileState identifies the version of a file on disk.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) (fileState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+
+	return fileState{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// reloader holds the current certificate and client CA pool, reloading them
+// lazily during handshakes when the files changed.
+type reloader struct {
+	opts   Options
+	logger *logrus.Entry
+
+	m         sync.Mutex
+	config    *tls.Config
+	states    map[string]fileState
+	lastCheck time.Time
+}
+
+func (r *reloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+
+	return files
+}
+
+func (r *reloader) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	config, err := r.configForClient(hello)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.Certificates[0], nil
+}
+
+func (r *reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if time.Since(r.lastCheck) >= r.opts.ReloadInterval {
+		r.lastCheck = time.Now()
+
+		if r.changed() {
+			if err := r.loadLocked(); err != nil {
+				r.logger.WithError(err).Error("Failed to reload TLS certificates, keeping the previous ones")
+			} else {
+				r.logger.Info("Reloaded TLS certificates")
+			}
+		}
+	}
+
+	return r.config, nil
+}
+
+func (r *reloader) changed() bool {
+	for _, f := range r.files() {
+		state, err := stat(f)
+		if err != nil || state != r.states[f] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *reloader) load() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.lastCheck = time.Now()
+
+	return r.loadLocked()
+}
+
+func (r *reloader) loadLocked() error {
+	states := make(map[string]fileState)
+	for _, f := range r.files() {
+		state, err := stat(f)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", f, err)
+		}
+
+		states[f] = state
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in client CA file %s", r.opts.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = r.opts.ClientAuth
+	}
+
+	r.config = config
+	r.states = states
+
+	return nil
+}
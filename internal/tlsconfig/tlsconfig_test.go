@@ -0,0 +1,62 @@
+package tlsconfig_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/theskch/prometheus-issue/internal/tlsconfig"
+	"github.com/theskch/prometheus-issue/internal/tlsconfig/tlsconfigtest"
+)
+
+func TestServerConfigReloadsCertificate(t *testing.T) {
+	ca := tlsconfigtest.NewAuthority(t)
+	certPEM, keyPEM := ca.Issue(t, 10, x509.ExtKeyUsageServerAuth)
+
+	opts := ca.Files(t, certPEM, keyPEM)
+	opts.ClientCAFile = ""
+	opts.ReloadInterval = time.Nanosecond
+
+	config, err := tlsconfig.NewServerConfig(opts)
+	if err != nil {
+		t.Fatalf("NewServerConfig() error = %s", err)
+	}
+
+	if serial := servedSerial(t, config); serial != 10 {
+		t.Fatalf("served serial = %d, want 10", serial)
+	}
+
+	certPEM, keyPEM = ca.Issue(t, 20, x509.ExtKeyUsageServerAuth)
+	tlsconfigtest.WriteFile(t, opts.KeyFile, keyPEM)
+	tlsconfigtest.WriteFile(t, opts.CertFile, certPEM)
+
+	// The modification time alone may not tell the files apart.
+	later := time.Now().Add(time.Minute)
+	for _, f := range []string{opts.CertFile, opts.KeyFile} {
+		if err := os.Chtimes(f, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if serial := servedSerial(t, config); serial != 20 {
+		t.Errorf("served serial after rewrite = %d, want 20", serial)
+	}
+}
+
+func servedSerial(t *testing.T, config *tls.Config) int64 {
+	t.Helper()
+
+	served, err := config.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("GetConfigForClient() error = %s", err)
+	}
+
+	leaf, err := x509.ParseCertificate(served.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("parse served certificate: %s", err)
+	}
+
+	return leaf.SerialNumber.Int64()
+}
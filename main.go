@@ -1,26 +1,46 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
+	"github.com/theskch/prometheus-issue/internal/config"
+	"github.com/theskch/prometheus-issue/internal/lifecycle"
 	"github.com/theskch/prometheus-issue/internal/monitoring"
 	"github.com/theskch/prometheus-issue/internal/service"
 )
 
-const (
-	monitoringServerAddress = ":9090"
-	serviceServerAddress    = ":8080"
-)
-
 func main() {
-	monitoringServer := monitoring.NewServer(monitoringServerAddress)
+	cfg, err := loadConfig()
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err)
+		os.Exit(2)
+	}
+
+	logrus.SetLevel(cfg.LogLevel())
+
 	log := logrus.NewEntry(logrus.StandardLogger())
 
+	monitoringServer, err := monitoring.NewServer(cfg.MonitoringOptions()...)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create monitoring server")
+	}
+
+	serviceServer, err := service.NewServer(monitoringServer.Registry(), cfg.ServiceOptions()...)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create service server")
+	}
+
 	log.Info("Starting monitoring server")
 	go func() {
 		if err := monitoringServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -29,24 +49,58 @@ func main() {
 	}()
 
 	log.Info("Starting service server")
-	serviceServer := service.NewServer(serviceServerAddress)
+	monitoringServer.AddReadinessCheck("service", monitoring.ServingCheck(serviceServer))
 	go func() {
 		if err := serviceServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.WithError(err).Error("Error while starting service server")
 		}
 	}()
 
+	registry := monitoringServer.Registry()
+	reloader := config.NewReloader(cfg, loadConfig, registry.ObserveConfigReload)
+	reloader.OnReload(func(c config.Config) {
+		logrus.SetLevel(c.LogLevel())
+	})
+	reloader.OnReload(func(c config.Config) {
+		serviceServer.ConfigureAccessLog(c.AccessLogOptions())
+	})
+	reloader.OnReload(func(c config.Config) {
+		serviceServer.ConfigureRateLimit(c.RateLimitOptions())
+	})
+	reloader.OnReload(func(c config.Config) {
+		registry.ConfigureIDLabel(c.IDLabelOptions())
+	})
+
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	<-signals
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
 
-	logrus.Info("Stopping service server")
-	if err := serviceServer.GracefulStop(); err != nil {
-		logrus.WithError(err).Fatal("Failed to gracefully stop the http server")
+		log.Info("Reloading configuration")
+		_ = reloader.Reload()
 	}
 
-	logrus.Info("Stopping monitoring server")
-	if err := monitoringServer.GracefulStop(); err != nil {
-		logrus.WithError(err).Warning("Failed to gracefully stop the monitoring server")
+	// A second SIGINT or SIGTERM cuts the graceful shutdown short.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for sig := range signals {
+			if sig != syscall.SIGHUP {
+				log.Warning("Forcing shutdown")
+				cancel()
+				return
+			}
+		}
+	}()
+
+	coordinator := lifecycle.NewCoordinator(cfg.Lifecycle(), serviceServer, monitoringServer)
+	if err := coordinator.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Fatal("Failed to gracefully shut down")
 	}
 }
+
+func loadConfig() (config.Config, error) {
+	return config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+}
@@ -20,6 +20,9 @@ import (
 type Error struct {
 	// Error Error description
 	Error string `json:"error"`
+
+	// RequestId Identifier of the request, echoed in the X-Request-ID response header
+	RequestId *string `json:"request_id,omitempty"`
 }
 
 // InternalServerError defines model for internalServerError.